@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/luccacabra/github-to-trello/resolver"
+	trelloSync "github.com/luccacabra/github-to-trello/syncer/trello"
+	"github.com/luccacabra/github-to-trello/trello"
+
+	"github.com/pkg/errors"
+)
+
+// validateBoard checks every list and label the config refers to against the
+// board, reporting all of them at once
+func validateBoard(trelloClient *trello.Client, actionResolver *resolver.Resolver, twoWayConf trelloSync.Config) error {
+	validator := trelloClient.Validator()
+	for _, reference := range actionResolver.ListReferences() {
+		validator.List(reference.Path, reference.Name)
+	}
+	for idx, name := range twoWayConf.DoneLists {
+		validator.List(fmt.Sprintf("two_way.done_lists[%d]", idx), name)
+	}
+	for _, reference := range actionResolver.LabelReferences() {
+		label := reference.Label
+		validator.Label(reference.Path, trello.Label{Name: label.Name, Color: label.Color})
+	}
+	return validator.Err()
+}
+
+// initBoard creates the lists and labels named by the sync actions and the two
+// way done lists that the board doesn't have yet
+func (a *app) initBoard(actionResolver *resolver.Resolver, twoWayConf trelloSync.Config) error {
+	lists := actionResolver.Lists()
+	for _, name := range twoWayConf.DoneLists {
+		if !contains(lists, name) {
+			lists = append(lists, name)
+		}
+	}
+
+	labels := []trello.Label{}
+	for _, label := range actionResolver.Labels() {
+		labels = append(labels, trello.Label{Name: label.Name, Color: label.Color})
+	}
+
+	if err := a.trello.InitBoard(lists, labels); err != nil {
+		return errors.Wrap(err, "Unable to initialize board")
+	}
+
+	if plan := a.trello.Plan(); plan != nil {
+		return writePlan(plan, *planOutput)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
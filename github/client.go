@@ -4,14 +4,37 @@ package github
 
 import (
 	"context"
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/shurcooL/githubql"
 	"golang.org/x/oauth2"
 )
 
+const (
+	defaultPageSize = 100
+	defaultMaxPages = 10
+)
+
 type Config struct {
 	OrgName  string
 	UserName string
+
+	// PageSize is the number of nodes requested per page of a connection.
+	PageSize int
+	// MaxPages caps the number of pages walked for a single connection.
+	MaxPages int
+	// RateLimitThreshold is the remaining point budget below which low
+	// priority queries are deferred.
+	RateLimitThreshold int
+
+	// BaseURL overrides the GraphQL endpoint, as GitHub Enterprise serves it
+	// elsewhere.
+	BaseURL string
 }
 
 type Client struct {
@@ -20,6 +43,11 @@ type Client struct {
 	orgName  string
 	userName string
 
+	pageSize int
+	maxPages int
+
+	budget *budget
+
 	common service
 
 	Issues       *IssuesService
@@ -35,11 +63,33 @@ func NewClient(token string, config Config) *Client {
 		&oauth2.Token{AccessToken: token},
 	)
 	httpClient := oauth2.NewClient(context.Background(), src)
+	httpClient.Transport = &secondaryLimitTransport{base: httpClient.Transport}
+
+	if len(config.BaseURL) > 0 {
+		return newClient(githubql.NewEnterpriseClient(config.BaseURL, httpClient), config)
+	}
+	return newClient(githubql.NewClient(httpClient), config)
+}
 
+// newClient applies config to a client sending queries through ql
+func newClient(ql *githubql.Client, config Config) *Client {
 	c := &Client{
-		githubql: githubql.NewClient(httpClient),
+		githubql: ql,
 		orgName:  config.OrgName,
 		userName: config.UserName,
+		pageSize: config.PageSize,
+		maxPages: config.MaxPages,
+		budget:   &budget{threshold: config.RateLimitThreshold},
+	}
+
+	if c.pageSize <= 0 || c.pageSize > defaultPageSize {
+		c.pageSize = defaultPageSize
+	}
+	if c.maxPages <= 0 {
+		c.maxPages = defaultMaxPages
+	}
+	if c.budget.threshold <= 0 {
+		c.budget.threshold = defaultRateLimitThreshold
 	}
 
 	c.common.client = c
@@ -57,6 +107,210 @@ func (c *Client) getUserName() string {
 	return c.userName
 }
 
+// UserName is the login of the user issues are synced for
+func (c *Client) UserName() string {
+	return c.userName
+}
+
+func (c *Client) getPageSize() githubql.Int {
+	return githubql.Int(c.pageSize)
+}
+
+func (c *Client) getMaxPages() int {
+	return c.maxPages
+}
+
+// cursor converts an empty cursor into a null GraphQL variable
+func cursor(after githubql.String) *githubql.String {
+	if len(after) == 0 {
+		return nil
+	}
+	return githubql.NewString(after)
+}
+
+// stateQualifier limits a search to open items, or when since is set to every
+// item updated since then so that items closed in the meantime are found too
+func stateQualifier(since time.Time) string {
+	if since.IsZero() {
+		return "is:open"
+	}
+	return "updated:>=" + since.UTC().Format("2006-01-02T15:04:05Z")
+}
+
 func (c *Client) prepareSearchQuery(search *Search) {
 	search.Query = githubql.String("\\\"" + search.Query + "\\\"")
 }
+
+// fetchRemainingComments walks the comment connection of an issue or pull
+// request past its first page, appending the results to comments
+func (c *Client) fetchRemainingComments(id, title githubql.String, comments *CommentConnection) error {
+	for page := 1; comments.PageInfo.HasNextPage; page++ {
+		if page >= c.getMaxPages() {
+			fmt.Printf("[WARNING] Stopped paging comments for \"%s\" after %d pages\n", title, page)
+			return nil
+		}
+
+		var Query struct {
+			RateLimit RateLimit
+			Node      struct {
+				Issue struct {
+					Comments CommentConnection `graphql:"comments(first: $first, after: $after)"`
+				} `graphql:"... on Issue"`
+				PullRequest struct {
+					Comments CommentConnection `graphql:"comments(first: $first, after: $after)"`
+				} `graphql:"... on PullRequest"`
+			} `graphql:"node(id: $id)"`
+		}
+
+		variables := map[string]interface{}{
+			"id":    githubql.ID(id),
+			"first": c.getPageSize(),
+			"after": cursor(comments.PageInfo.EndCursor),
+		}
+
+		if err := c.query(&Query, variables, HIGH); err != nil {
+			return errors.Wrapf(err, "Error querying comments for \"%s\"", title)
+		}
+
+		// only the fragment matching the node's type is populated
+		next := Query.Node.Issue.Comments
+		if len(Query.Node.PullRequest.Comments.Edges) > 0 {
+			next = Query.Node.PullRequest.Comments
+		}
+		comments.Edges = append(comments.Edges, next.Edges...)
+		comments.PageInfo = next.PageInfo
+	}
+	return nil
+}
+
+// ResolveURL returns the node ID of the issue or pull request at url, empty if
+// there is none
+func (c *Client) ResolveURL(url string) (string, error) {
+	type node struct {
+		ID githubql.String
+	}
+	var Query struct {
+		RateLimit RateLimit
+		Resource  struct {
+			Issue       node `graphql:"... on Issue"`
+			PullRequest node `graphql:"... on PullRequest"`
+		} `graphql:"resource(url: $url)"`
+	}
+
+	uri, err := neturl.Parse(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid GitHub URL %s", url)
+	}
+	variables := map[string]interface{}{
+		"url": githubql.URI{URL: uri},
+	}
+
+	if err := c.query(&Query, variables, HIGH); err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "Error resolving %s", url)
+	}
+
+	if len(Query.Resource.PullRequest.ID) > 0 {
+		return string(Query.Resource.PullRequest.ID), nil
+	}
+	return string(Query.Resource.Issue.ID), nil
+}
+
+// labelsPageSize is the most labels GitHub returns per page
+const labelsPageSize = 100
+
+// fetchRemainingLabels walks the label connection of an issue or pull request
+// past its first page, appending the results to labels
+func (c *Client) fetchRemainingLabels(id, title githubql.String, labels *LabelConnection) error {
+	for page := 1; labels.PageInfo.HasNextPage; page++ {
+		if page >= c.getMaxPages() {
+			fmt.Printf("[WARNING] Stopped paging labels for \"%s\" after %d pages\n", title, page)
+			return nil
+		}
+
+		var Query struct {
+			RateLimit RateLimit
+			Node      struct {
+				Issue struct {
+					Labels LabelConnection `graphql:"labels(first: $first, after: $after)"`
+				} `graphql:"... on Issue"`
+				PullRequest struct {
+					Labels LabelConnection `graphql:"labels(first: $first, after: $after)"`
+				} `graphql:"... on PullRequest"`
+			} `graphql:"node(id: $id)"`
+		}
+
+		variables := map[string]interface{}{
+			"id":    githubql.ID(id),
+			"first": githubql.Int(labelsPageSize),
+			"after": cursor(labels.PageInfo.EndCursor),
+		}
+
+		if err := c.query(&Query, variables, HIGH); err != nil {
+			return errors.Wrapf(err, "Error querying labels for \"%s\"", title)
+		}
+
+		// only the fragment matching the node's type is populated
+		next := Query.Node.Issue.Labels
+		if len(Query.Node.PullRequest.Labels.Nodes) > 0 {
+			next = Query.Node.PullRequest.Labels
+		}
+		labels.Nodes = append(labels.Nodes, next.Nodes...)
+		labels.PageInfo = next.PageInfo
+	}
+	return nil
+}
+
+// mentions reports whether any of texts @-mentions login
+func mentions(login string, texts ...githubql.String) bool {
+	mention := regexp.MustCompile(`(?i)(^|[^\w])@` + regexp.QuoteMeta(login) + `\b`)
+	for _, text := range texts {
+		if mention.MatchString(string(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+func commentBodies(comments CommentConnection) []githubql.String {
+	bodies := make([]githubql.String, len(comments.Edges))
+	for idx, comment := range comments.Edges {
+		bodies[idx] = comment.Node.Body
+	}
+	return bodies
+}
+
+// Logins lists the logins of the users
+func (u userConnection) Logins() []string {
+	logins := make([]string, len(u.Nodes))
+	for idx, user := range u.Nodes {
+		logins[idx] = string(user.Login)
+	}
+	return logins
+}
+
+// Users lists the users requested to review, team requests are left out
+func (r ReviewRequestConnection) Users() []User {
+	users := []User{}
+	for _, request := range r.Nodes {
+		if user := request.RequestedReviewer.User; len(user.Login) > 0 {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func containsLogin(users userConnection, login string) bool {
+	for _, user := range users.Nodes {
+		if strings.EqualFold(string(user.Login), login) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "Could not resolve to a node")
+}
@@ -0,0 +1,245 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubql"
+)
+
+const testRateLimit = `"rateLimit": {"cost": 1, "remaining": 4000, "resetAt": "2030-01-02T15:04:05Z"}`
+
+// graphQLServer answers search queries with search and comment queries with
+// comments, each given the after cursor, and counts the queries of both kinds.
+// The first limited requests are rejected by a secondary rate limit with
+// limitStatus and a Retry-After of a second.
+type graphQLServer struct {
+	search   func(after string) string
+	comments func(after string) string
+
+	// rateLimit is reported by every query, testRateLimit when empty
+	rateLimit   string
+	limited     int
+	limitStatus int
+
+	requests       int
+	searches       int
+	commentQueries int
+}
+
+func (s *graphQLServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	after, _ := request.Variables["after"].(string)
+
+	s.requests++
+	if s.requests <= s.limited {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "You have exceeded a secondary rate limit", s.limitStatus)
+		return
+	}
+
+	var data string
+	switch {
+	case strings.Contains(request.Query, "search("):
+		s.searches++
+		data = s.search(after)
+	case strings.Contains(request.Query, "comments(first: $first, after: $after)"):
+		s.commentQueries++
+		data = fmt.Sprintf(`"node": {"comments": %s}`, s.comments(after))
+	default:
+		http.Error(w, "unexpected query "+request.Query, http.StatusBadRequest)
+		return
+	}
+	rateLimit := testRateLimit
+	if len(s.rateLimit) > 0 {
+		rateLimit = fmt.Sprintf(`"rateLimit": %s`, s.rateLimit)
+	}
+	fmt.Fprintf(w, `{"data": {%s, %s}}`, rateLimit, data)
+}
+
+func newTestClient(server *httptest.Server, config Config) *Client {
+	config.OrgName = "org"
+	config.UserName = "octocat"
+	httpClient := server.Client()
+	httpClient.Transport = &secondaryLimitTransport{base: httpClient.Transport}
+	return newClient(githubql.NewEnterpriseClient(server.URL, httpClient), config)
+}
+
+// issueJSON is a search edge for an issue whose first comment page holds a
+// single comment, continuing at cursor when it isn't empty
+func issueJSON(id, cursor string) string {
+	return fmt.Sprintf(
+		`{"node": {"id": %q, "title": %q, "state": "OPEN", "comments": %s, "labels": {"nodes": [], "pageInfo": {"hasNextPage": false}}}}`,
+		id,
+		"Issue "+id,
+		commentsJSON(id+"-0", cursor),
+	)
+}
+
+// commentsJSON is a comment connection holding comment id, with another page
+// at cursor when it isn't empty
+func commentsJSON(id, cursor string) string {
+	return fmt.Sprintf(
+		`{"edges": [{"node": {"id": %q, "body": "comment"}}], "pageInfo": {"endCursor": %q, "hasNextPage": %t}}`,
+		id,
+		cursor,
+		len(cursor) > 0,
+	)
+}
+
+func searchJSON(cursor string, issues ...string) string {
+	return fmt.Sprintf(
+		`"search": {"edges": [%s], "pageInfo": {"endCursor": %q, "hasNextPage": %t}}`,
+		strings.Join(issues, ", "),
+		cursor,
+		len(cursor) > 0,
+	)
+}
+
+func TestSearchPages(t *testing.T) {
+	fake := &graphQLServer{
+		search: func(after string) string {
+			switch after {
+			case "":
+				return searchJSON("page-2", issueJSON("a", ""))
+			case "page-2":
+				return searchJSON("", issueJSON("b", ""), issueJSON("c", ""))
+			}
+			t.Errorf("unexpected search cursor %q", after)
+			return searchJSON("")
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := newTestClient(server, Config{})
+	issues, err := c.Issues.Assigned(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues over 2 pages, got %d", len(issues))
+	}
+	for idx, id := range []string{"a", "b", "c"} {
+		if string(issues[idx].Issue.ID) != id {
+			t.Errorf("expected issue %d to be %q, got %q", idx, id, issues[idx].Issue.ID)
+		}
+	}
+	if fake.searches != 2 {
+		t.Errorf("expected 2 search queries, got %d", fake.searches)
+	}
+	if usage := c.Usage(); usage.Queries != 2 || usage.Remaining != 4000 {
+		t.Errorf("expected the rate limit of both queries to be recorded, got %+v", usage)
+	}
+}
+
+func TestSearchMaxPages(t *testing.T) {
+	fake := &graphQLServer{
+		search: func(after string) string {
+			return searchJSON(after+"+", issueJSON("issue"+after, ""))
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	issues, err := newTestClient(server, Config{MaxPages: 3}).Issues.Assigned(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.searches != 3 || len(issues) != 3 {
+		t.Errorf("expected paging to stop after 3 pages, got %d queries and %d issues", fake.searches, len(issues))
+	}
+}
+
+func TestCommentPages(t *testing.T) {
+	fake := &graphQLServer{
+		search: func(after string) string {
+			return searchJSON("", issueJSON("a", "comments-2"))
+		},
+		comments: func(after string) string {
+			switch after {
+			case "comments-2":
+				return commentsJSON("a-1", "comments-3")
+			case "comments-3":
+				return commentsJSON("a-2", "")
+			}
+			t.Errorf("unexpected comments cursor %q", after)
+			return commentsJSON("", "")
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	issues, err := newTestClient(server, Config{}).Issues.Assigned(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d", len(issues))
+	}
+	comments := issues[0].Issue.Comments
+	if len(comments.Edges) != 3 {
+		t.Fatalf("expected 3 comments over 3 pages, got %d", len(comments.Edges))
+	}
+	for idx, edge := range comments.Edges {
+		if id := fmt.Sprintf("a-%d", idx); string(edge.Node.ID) != id {
+			t.Errorf("expected comment %d to be %q, got %q", idx, id, edge.Node.ID)
+		}
+	}
+	if comments.PageInfo.HasNextPage {
+		t.Error("expected the last comment page to be recorded")
+	}
+}
+
+func TestCommentMaxPages(t *testing.T) {
+	fake := &graphQLServer{
+		search: func(after string) string {
+			return searchJSON("", issueJSON("a", "more"))
+		},
+		comments: func(after string) string {
+			return commentsJSON("a-"+after, after+"+")
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	issues, err := newTestClient(server, Config{MaxPages: 3}).Issues.Assigned(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first page comes with the search, leaving 2 pages to query
+	if fake.commentQueries != 2 {
+		t.Errorf("expected 2 comment queries, got %d", fake.commentQueries)
+	}
+	if len(issues[0].Issue.Comments.Edges) != 3 {
+		t.Errorf("expected 3 comments, got %d", len(issues[0].Issue.Comments.Edges))
+	}
+}
+
+func TestMutationsSpendBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"closeIssue": {"issue": {"id": "issue"}}}}`)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server, Config{})
+	c.budget.record(RateLimit{Cost: 1, Remaining: 100, ResetAt: githubql.DateTime{Time: time.Now().Add(time.Hour)}})
+	if err := c.Issues.Close("issue"); err != nil {
+		t.Fatal(err)
+	}
+	if usage := c.Usage(); usage.Queries != 2 || usage.Remaining != 100-mutationCost {
+		t.Errorf("expected the mutation to be counted, got %+v", usage)
+	}
+}
@@ -1,8 +1,9 @@
 package github
 
 import (
-	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/shurcooL/githubql"
@@ -10,10 +11,13 @@ import (
 
 type IssuesService service
 
-func (i *IssuesService) Assigned() ([]IssueNode, error) {
+// Assigned returns open issues assigned to the user, or with a non zero since
+// every assigned issue updated since then, closed ones included
+func (i *IssuesService) Assigned(since time.Time) ([]IssueNode, error) {
 	query := githubql.String(
 		fmt.Sprintf(
-			"is:open assignee:%s org:%s archived:false",
+			"%s is:issue assignee:%s org:%s archived:false",
+			stateQualifier(since),
 			i.client.getUserName(),
 			i.client.getOrgName(),
 		),
@@ -21,8 +25,9 @@ func (i *IssuesService) Assigned() ([]IssueNode, error) {
 	issues, err := i.searchIssue(
 		Search{
 			Query: query,
-			First: 100,
+			First: i.client.getPageSize(),
 		},
+		i.client.getMaxPages(),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error querying open assigned issues")
@@ -30,10 +35,13 @@ func (i *IssuesService) Assigned() ([]IssueNode, error) {
 	return issues, nil
 }
 
-func (i *IssuesService) Mentioned() ([]IssueNode, error) {
+// Mentioned returns open issues mentioning the user, or with a non zero since
+// every mentioning issue updated since then, closed ones included
+func (i *IssuesService) Mentioned(since time.Time) ([]IssueNode, error) {
 	query := githubql.String(
 		fmt.Sprintf(
-			"is:open mentions:%s -author:%s org:%s archived:false",
+			"%s is:issue mentions:%s -author:%s org:%s archived:false",
+			stateQualifier(since),
 			i.client.getUserName(),
 			i.client.getUserName(),
 			i.client.getOrgName(),
@@ -41,9 +49,11 @@ func (i *IssuesService) Mentioned() ([]IssueNode, error) {
 	)
 	issues, err := i.searchIssue(
 		Search{
-			Query: query,
-			First: 100,
+			Query:    query,
+			First:    i.client.getPageSize(),
+			Priority: LOW,
 		},
+		i.client.getMaxPages(),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error querying open assigned issues")
@@ -51,64 +61,172 @@ func (i *IssuesService) Mentioned() ([]IssueNode, error) {
 	return issues, nil
 }
 
-func (i *IssuesService) IsClosed(issueName, issueId string) (bool, error) {
-	query := githubql.String(
-		fmt.Sprintf(
-			"is:closed archived:false org %s \"%s\"",
-			i.client.getOrgName(),
-			issueName,
-		),
-	)
-	issues, err := i.searchIssue(
-		Search{
-			Query: query,
-			First: 1,
-		},
-	)
+func (i *IssuesService) IsClosed(issueId string) (bool, error) {
+	status, err := i.Status(issueId)
 	if err != nil {
-		return false, errors.Wrap(err, "Error querying closed assigned issues")
+		return false, errors.Wrap(err, "Error querying closed issue")
+	}
+	return status.Found && status.State != OPEN, nil
+}
+
+// Status looks up the current state of an issue or pull request by node ID
+func (i *IssuesService) Status(issueId string) (*IssueStatus, error) {
+	type node struct {
+		State      githubql.String
+		Repository struct {
+			Name  githubql.String
+			Owner struct {
+				Login githubql.String
+			}
+		}
 	}
-	for _, issue := range issues {
-		if string(issue.Issue.ID) == issueId {
-			return true, nil
+	var Query struct {
+		RateLimit RateLimit
+		Node      struct {
+			Issue       node `graphql:"... on Issue"`
+			PullRequest node `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	variables := map[string]interface{}{
+		"id": githubql.ID(issueId),
+	}
+
+	if err := i.client.query(&Query, variables, HIGH); err != nil {
+		if isNotFound(err) {
+			return &IssueStatus{Found: false}, nil
 		}
+		return nil, errors.Wrapf(err, "Error querying status of issue %s", issueId)
+	}
+
+	// only the fragment matching the node's type is populated
+	result := Query.Node.Issue
+	if len(Query.Node.PullRequest.State) > 0 {
+		result = Query.Node.PullRequest
 	}
-	return false, nil
+	if len(result.State) == 0 {
+		return &IssueStatus{Found: false}, nil
+	}
+
+	return &IssueStatus{
+		Found:      true,
+		State:      IssueState(result.State),
+		Repository: string(result.Repository.Name),
+		InOrg:      strings.EqualFold(string(result.Repository.Owner.Login), i.client.getOrgName()),
+	}, nil
 }
 
-func (i *IssuesService) searchIssue(search Search) ([]IssueNode, error) {
-	search.Type = ISSUE
-	i.client.prepareSearchQuery(&search)
+// Get fetches a single issue by node ID along with the user's relations to it,
+// returning nil if the issue no longer exists
+func (i *IssuesService) Get(issueId string) (*IssueItem, error) {
+	var Query struct {
+		RateLimit RateLimit
+		Node      IssueNode `graphql:"node(id: $id)"`
+	}
 
 	variables := map[string]interface{}{
-		"searchQuery": search.Query,
-		"type":        search.Type,
-		"first":       search.First,
+		"id":            githubql.ID(issueId),
+		"commentsFirst": i.client.getPageSize(),
 	}
 
-	type Node struct {
-		Node IssueNode
+	if err := i.client.query(&Query, variables, HIGH); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Error querying issue %s", issueId)
 	}
-	var Query struct {
-		Search struct {
-			Edges []Node
-		} `graphql:"search(query: $searchQuery, type: $type, first: $first)"`
+
+	issue := &Query.Node.Issue
+	if len(issue.ID) == 0 {
+		return nil, nil
+	}
+	if err := i.client.fetchRemainingComments(issue.ID, issue.Title, &issue.Comments); err != nil {
+		return nil, err
+	}
+	if err := i.client.fetchRemainingLabels(issue.ID, issue.Title, &issue.Labels); err != nil {
+		return nil, err
 	}
 
-	if err := i.client.githubql.Query(
-		context.Background(),
-		&Query,
-		variables,
-	); err != nil {
-		return nil, errors.Wrap(err, "Error querying open assigned issues")
+	userName := i.client.getUserName()
+	isAuthor := strings.EqualFold(string(issue.Author.Login), userName)
+	return &IssueItem{
+		Node:  Query.Node,
+		State: IssueState(issue.State),
+		Relations: Relations{
+			Assignee: containsLogin(issue.Assignees, userName),
+			Author:   isAuthor,
+			// mirrors the "mentions:<user> -author:<user>" search
+			Mentioned: !isAuthor && mentions(userName, append(commentBodies(issue.Comments), issue.Body)...),
+		},
+	}, nil
+}
+
+// searchIssue walks the search connection page by page until it is exhausted
+// or maxPages pages have been read
+func (i *IssuesService) searchIssue(search Search, maxPages int) ([]IssueNode, error) {
+	search.Type = ISSUE
+	i.client.prepareSearchQuery(&search)
+
+	type Node struct {
+		Node IssueNode
 	}
 
-	nodes := Query.Search.Edges
-	issues := make([]IssueNode, len(nodes))
+	issues := []IssueNode{}
+	for page := 0; page < maxPages; page++ {
+		var Query struct {
+			RateLimit RateLimit
+			Search    struct {
+				Edges    []Node
+				PageInfo PageInfo
+			} `graphql:"search(query: $searchQuery, type: $type, first: $first, after: $after)"`
+		}
+
+		variables := map[string]interface{}{
+			"searchQuery":   search.Query,
+			"type":          search.Type,
+			"first":         search.First,
+			"after":         cursor(search.After),
+			"commentsFirst": i.client.getPageSize(),
+		}
+
+		if err := i.client.query(&Query, variables, search.Priority); err != nil {
+			return nil, errors.Wrapf(err, "Error querying issues page %d", page+1)
+		}
+
+		for _, node := range Query.Search.Edges {
+			issues = append(issues, node.Node)
+		}
 
-	for idx, node := range nodes {
-		issues[idx] = node.Node
+		if !Query.Search.PageInfo.HasNextPage {
+			break
+		}
+		if page == maxPages-1 {
+			fmt.Printf("[WARNING] Stopped paging issues for %s after %d pages\n", search.Query, maxPages)
+		}
+		search.After = Query.Search.PageInfo.EndCursor
 	}
 
+	if err := i.completeComments(issues); err != nil {
+		return nil, err
+	}
 	return issues, nil
 }
+
+// completeComments fetches any comments and labels beyond the first page for
+// each issue
+func (i *IssuesService) completeComments(issues []IssueNode) error {
+	for idx := range issues {
+		issue := &issues[idx].Issue
+		if err := i.client.fetchRemainingComments(
+			issue.ID,
+			issue.Title,
+			&issue.Comments,
+		); err != nil {
+			return err
+		}
+		if err := i.client.fetchRemainingLabels(issue.ID, issue.Title, &issue.Labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
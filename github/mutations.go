@@ -0,0 +1,113 @@
+/* GraphQL mutations */
+
+package github
+
+import (
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubql"
+)
+
+// Input types missing from the vendored githubql schema. The type names are
+// sent as the GraphQL variable types so they must match the schema.
+
+type CloseIssueInput struct {
+	IssueID githubql.ID `json:"issueId"`
+}
+
+type ReopenIssueInput struct {
+	IssueID githubql.ID `json:"issueId"`
+}
+
+type ClosePullRequestInput struct {
+	PullRequestID githubql.ID `json:"pullRequestId"`
+}
+
+type ReopenPullRequestInput struct {
+	PullRequestID githubql.ID `json:"pullRequestId"`
+}
+
+// AddComment comments on an issue or pull request, returning the new comment's node ID
+func (c *Client) AddComment(subjectId, body string) (string, error) {
+	var Mutation struct {
+		AddComment struct {
+			CommentEdge struct {
+				Node struct {
+					ID githubql.String
+				}
+			}
+		} `graphql:"addComment(input: $input)"`
+	}
+
+	input := githubql.AddCommentInput{
+		SubjectID: githubql.ID(subjectId),
+		Body:      githubql.String(body),
+	}
+	if err := c.mutate(&Mutation, input); err != nil {
+		return "", errors.Wrapf(err, "Error commenting on %s", subjectId)
+	}
+	return string(Mutation.AddComment.CommentEdge.Node.ID), nil
+}
+
+func (i *IssuesService) Close(issueId string) error {
+	var Mutation struct {
+		CloseIssue struct {
+			Issue struct {
+				ID githubql.String
+			}
+		} `graphql:"closeIssue(input: $input)"`
+	}
+
+	input := CloseIssueInput{IssueID: githubql.ID(issueId)}
+	if err := i.client.mutate(&Mutation, input); err != nil {
+		return errors.Wrapf(err, "Error closing issue %s", issueId)
+	}
+	return nil
+}
+
+func (i *IssuesService) Reopen(issueId string) error {
+	var Mutation struct {
+		ReopenIssue struct {
+			Issue struct {
+				ID githubql.String
+			}
+		} `graphql:"reopenIssue(input: $input)"`
+	}
+
+	input := ReopenIssueInput{IssueID: githubql.ID(issueId)}
+	if err := i.client.mutate(&Mutation, input); err != nil {
+		return errors.Wrapf(err, "Error reopening issue %s", issueId)
+	}
+	return nil
+}
+
+func (p *PullRequestService) Close(pullRequestId string) error {
+	var Mutation struct {
+		ClosePullRequest struct {
+			PullRequest struct {
+				ID githubql.String
+			}
+		} `graphql:"closePullRequest(input: $input)"`
+	}
+
+	input := ClosePullRequestInput{PullRequestID: githubql.ID(pullRequestId)}
+	if err := p.client.mutate(&Mutation, input); err != nil {
+		return errors.Wrapf(err, "Error closing pull request %s", pullRequestId)
+	}
+	return nil
+}
+
+func (p *PullRequestService) Reopen(pullRequestId string) error {
+	var Mutation struct {
+		ReopenPullRequest struct {
+			PullRequest struct {
+				ID githubql.String
+			}
+		} `graphql:"reopenPullRequest(input: $input)"`
+	}
+
+	input := ReopenPullRequestInput{PullRequestID: githubql.ID(pullRequestId)}
+	if err := p.client.mutate(&Mutation, input); err != nil {
+		return errors.Wrapf(err, "Error reopening pull request %s", pullRequestId)
+	}
+	return nil
+}
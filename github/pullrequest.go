@@ -1,3 +1,259 @@
 package github
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubql"
+)
+
 type PullRequestService service
+
+func (p *PullRequestService) Assigned() ([]PullRequestNode, error) {
+	query := githubql.String(
+		fmt.Sprintf(
+			"is:open is:pr assignee:%s org:%s archived:false",
+			p.client.getUserName(),
+			p.client.getOrgName(),
+		),
+	)
+	pullRequests, err := p.search(query, HIGH)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error querying open assigned pull requests")
+	}
+	return pullRequests, nil
+}
+
+func (p *PullRequestService) Authored() ([]PullRequestNode, error) {
+	query := githubql.String(
+		fmt.Sprintf(
+			"is:open is:pr author:%s org:%s archived:false",
+			p.client.getUserName(),
+			p.client.getOrgName(),
+		),
+	)
+	pullRequests, err := p.search(query, HIGH)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error querying open authored pull requests")
+	}
+	return pullRequests, nil
+}
+
+func (p *PullRequestService) Mentioned() ([]PullRequestNode, error) {
+	query := githubql.String(
+		fmt.Sprintf(
+			"is:open is:pr mentions:%s -author:%s org:%s archived:false",
+			p.client.getUserName(),
+			p.client.getUserName(),
+			p.client.getOrgName(),
+		),
+	)
+	pullRequests, err := p.search(query, LOW)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error querying open mentioned pull requests")
+	}
+	return pullRequests, nil
+}
+
+// ReviewRequested returns open pull requests awaiting a review from the user directly
+func (p *PullRequestService) ReviewRequested() ([]PullRequestNode, error) {
+	query := githubql.String(
+		fmt.Sprintf(
+			"is:open is:pr user-review-requested:%s org:%s archived:false",
+			p.client.getUserName(),
+			p.client.getOrgName(),
+		),
+	)
+	pullRequests, err := p.search(query, HIGH)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error querying open review requested pull requests")
+	}
+	return pullRequests, nil
+}
+
+// TeamReviewRequested returns open pull requests awaiting a review from one of
+// the user's teams but not from the user directly
+func (p *PullRequestService) TeamReviewRequested() ([]PullRequestNode, error) {
+	query := githubql.String(
+		fmt.Sprintf(
+			"is:open is:pr review-requested:%s org:%s archived:false",
+			p.client.getUserName(),
+			p.client.getOrgName(),
+		),
+	)
+	anyRequested, err := p.search(query, LOW)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error querying open team review requested pull requests")
+	}
+
+	userRequested, err := p.ReviewRequested()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error querying open team review requested pull requests")
+	}
+	userRequestedIds := map[githubql.String]bool{}
+	for _, pullRequest := range userRequested {
+		userRequestedIds[pullRequest.PullRequest.ID] = true
+	}
+
+	pullRequests := []PullRequestNode{}
+	for _, pullRequest := range anyRequested {
+		if !userRequestedIds[pullRequest.PullRequest.ID] {
+			pullRequests = append(pullRequests, pullRequest)
+		}
+	}
+	return pullRequests, nil
+}
+
+// Get fetches a single pull request by node ID along with the user's relations
+// to it, returning nil if the pull request no longer exists
+func (p *PullRequestService) Get(pullRequestId string) (*PullRequestItem, error) {
+	var Query struct {
+		RateLimit RateLimit
+		Node      struct {
+			PullRequestNode
+			Details struct {
+				Assignees      userConnection `graphql:"assignees(first: 100)"`
+				State          githubql.String
+				ReviewRequests struct {
+					Nodes []struct {
+						RequestedReviewer struct {
+							User struct {
+								Login githubql.String
+							} `graphql:"... on User"`
+							Team struct {
+								Members struct {
+									TotalCount githubql.Int
+								} `graphql:"members(query: $login)"`
+							} `graphql:"... on Team"`
+						}
+					}
+				} `graphql:"reviewRequests(first: 100)"`
+			} `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+
+	variables := map[string]interface{}{
+		"id":            githubql.ID(pullRequestId),
+		"commentsFirst": p.client.getPageSize(),
+		"login":         githubql.String(p.client.getUserName()),
+	}
+
+	if err := p.client.query(&Query, variables, HIGH); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Error querying pull request %s", pullRequestId)
+	}
+
+	pullRequest := &Query.Node.PullRequestNode.PullRequest
+	if len(pullRequest.ID) == 0 {
+		return nil, nil
+	}
+	if err := p.client.fetchRemainingComments(pullRequest.ID, pullRequest.Title, &pullRequest.Comments); err != nil {
+		return nil, err
+	}
+	if err := p.client.fetchRemainingLabels(pullRequest.ID, pullRequest.Title, &pullRequest.Labels); err != nil {
+		return nil, err
+	}
+
+	details := Query.Node.Details
+	userName := p.client.getUserName()
+	isAuthor := strings.EqualFold(string(pullRequest.Author.Login), userName)
+	relations := Relations{
+		Assignee: containsLogin(details.Assignees, userName),
+		Author:   isAuthor,
+		// mirrors the "mentions:<user> -author:<user>" search
+		Mentioned: !isAuthor && mentions(userName, append(commentBodies(pullRequest.Comments), pullRequest.Body)...),
+	}
+	for _, request := range details.ReviewRequests.Nodes {
+		reviewer := request.RequestedReviewer
+		if strings.EqualFold(string(reviewer.User.Login), userName) {
+			relations.ReviewRequested = true
+		} else if reviewer.Team.Members.TotalCount > 0 {
+			relations.TeamReviewRequested = true
+		}
+	}
+	// matches TeamReviewRequested, direct requests take precedence over team ones
+	if relations.ReviewRequested {
+		relations.TeamReviewRequested = false
+	}
+
+	return &PullRequestItem{
+		Node:      Query.Node.PullRequestNode,
+		State:     IssueState(details.State),
+		Relations: relations,
+	}, nil
+}
+
+func (p *PullRequestService) search(query githubql.String, priority Priority) ([]PullRequestNode, error) {
+	return p.searchPullRequest(
+		Search{
+			Query:    query,
+			First:    p.client.getPageSize(),
+			Priority: priority,
+		},
+		p.client.getMaxPages(),
+	)
+}
+
+// searchPullRequest walks the search connection page by page until it is
+// exhausted or maxPages pages have been read
+func (p *PullRequestService) searchPullRequest(search Search, maxPages int) ([]PullRequestNode, error) {
+	search.Type = ISSUE
+	p.client.prepareSearchQuery(&search)
+
+	type Node struct {
+		Node PullRequestNode
+	}
+
+	pullRequests := []PullRequestNode{}
+	for page := 0; page < maxPages; page++ {
+		var Query struct {
+			RateLimit RateLimit
+			Search    struct {
+				Edges    []Node
+				PageInfo PageInfo
+			} `graphql:"search(query: $searchQuery, type: $type, first: $first, after: $after)"`
+		}
+
+		variables := map[string]interface{}{
+			"searchQuery":   search.Query,
+			"type":          search.Type,
+			"first":         search.First,
+			"after":         cursor(search.After),
+			"commentsFirst": p.client.getPageSize(),
+		}
+
+		if err := p.client.query(&Query, variables, search.Priority); err != nil {
+			return nil, errors.Wrapf(err, "Error querying pull requests page %d", page+1)
+		}
+
+		for _, node := range Query.Search.Edges {
+			pullRequests = append(pullRequests, node.Node)
+		}
+
+		if !Query.Search.PageInfo.HasNextPage {
+			break
+		}
+		if page == maxPages-1 {
+			fmt.Printf("[WARNING] Stopped paging pull requests for %s after %d pages\n", search.Query, maxPages)
+		}
+		search.After = Query.Search.PageInfo.EndCursor
+	}
+
+	for idx := range pullRequests {
+		pullRequest := &pullRequests[idx].PullRequest
+		if err := p.client.fetchRemainingComments(
+			pullRequest.ID,
+			pullRequest.Title,
+			&pullRequest.Comments,
+		); err != nil {
+			return nil, err
+		}
+		if err := p.client.fetchRemainingLabels(pullRequest.ID, pullRequest.Title, &pullRequest.Labels); err != nil {
+			return nil, err
+		}
+	}
+	return pullRequests, nil
+}
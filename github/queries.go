@@ -23,6 +23,30 @@ type Search struct {
 
 	Query githubql.String
 	Type  SearchType
+
+	// Priority is not sent, low priority searches are deferred first
+	Priority Priority
+}
+
+type PageInfo struct {
+	EndCursor   githubql.String
+	HasNextPage githubql.Boolean
+}
+
+type IssueState string
+
+const (
+	OPEN   IssueState = "OPEN"
+	CLOSED            = "CLOSED"
+	MERGED            = "MERGED"
+)
+
+// IssueStatus is the current state of an issue or pull request looked up by node ID
+type IssueStatus struct {
+	Found      bool // false when the node was deleted or is no longer visible
+	State      IssueState
+	Repository string
+	InOrg      bool // false when the node was transferred out of the configured org
 }
 
 type CommentNode struct {
@@ -30,24 +54,118 @@ type CommentNode struct {
 		Author struct {
 			Login githubql.String
 		}
-		Body githubql.String
-		URL  githubql.String
+		Body      githubql.String
+		CreatedAt githubql.DateTime
+		ID        githubql.String
+		UpdatedAt githubql.DateTime
+		URL       githubql.String
 	}
 }
 
+type CommentConnection struct {
+	Edges    []CommentNode
+	PageInfo PageInfo
+}
+
+type LabelConnection struct {
+	Nodes []struct {
+		Color githubql.String
+		Name  githubql.String
+	}
+	PageInfo PageInfo
+}
+
+// Milestone is an item's milestone, DueOn is zero when it has no due date
+type Milestone struct {
+	DueOn githubql.DateTime
+	Title githubql.String
+}
+
 type IssueNode struct {
 	Issue struct {
-		Body     githubql.String
-		Comments struct {
-			Edges []CommentNode
-		} `graphql:"comments(last:100)"`
+		Assignees userConnection `graphql:"assignees(first: 100)"`
+		Author    struct {
+			Login githubql.String
+		}
+		Body       githubql.String
+		Comments   CommentConnection `graphql:"comments(first: $commentsFirst)"`
 		CreatedAt  githubql.DateTime
 		ID         githubql.String
+		Labels     LabelConnection `graphql:"labels(first: 20)"`
+		Milestone  Milestone
 		Number     githubql.Int
 		Repository struct {
 			Name githubql.String
 		}
+		State githubql.String
 		Title githubql.String
 		URL   githubql.String
 	} `graphql:"... on Issue"`
 }
+
+type PullRequestNode struct {
+	PullRequest struct {
+		Assignees userConnection `graphql:"assignees(first: 100)"`
+		Author    struct {
+			Login githubql.String
+		}
+		BaseRefName githubql.String
+		Body        githubql.String
+		Comments    CommentConnection `graphql:"comments(first: $commentsFirst)"`
+		CreatedAt   githubql.DateTime
+		HeadRefName githubql.String
+		ID          githubql.String
+		Labels      LabelConnection `graphql:"labels(first: 20)"`
+		Milestone   Milestone
+		Number      githubql.Int
+		Repository  struct {
+			Name githubql.String
+		}
+		ReviewRequests ReviewRequestConnection `graphql:"reviewRequests(first: 100)"`
+		Title          githubql.String
+		URL            githubql.String
+	} `graphql:"... on PullRequest"`
+}
+
+// User is a GitHub user, Name is empty when the user hasn't set one
+type User struct {
+	Login githubql.String
+	Name  githubql.String
+}
+
+type userConnection struct {
+	Nodes []User
+}
+
+// ReviewRequestConnection holds the reviews requested on a pull request, team
+// requests leave User empty
+type ReviewRequestConnection struct {
+	Nodes []struct {
+		RequestedReviewer struct {
+			User User `graphql:"... on User"`
+		}
+	}
+}
+
+// Relations describes how the configured user relates to a single issue or pull request
+type Relations struct {
+	Assignee            bool
+	Author              bool
+	Mentioned           bool
+	ReviewRequested     bool
+	TeamReviewRequested bool
+}
+
+// IssueItem is a single issue fetched by node ID
+type IssueItem struct {
+	Node      IssueNode
+	State     IssueState
+	Relations Relations
+}
+
+// PullRequestItem is a single pull request fetched by node ID
+type PullRequestItem struct {
+	Node      PullRequestNode
+	State     IssueState
+	Relations Relations
+}
@@ -0,0 +1,249 @@
+/* GraphQL point budget and secondary rate limit handling */
+
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubql"
+)
+
+const (
+	defaultRateLimitThreshold = 500
+
+	// minRemaining is kept back for high priority queries, below it they
+	// wait for the budget to reset
+	minRemaining = 10
+
+	// mutationCost is the points GitHub charges a mutation
+	mutationCost = 1
+
+	maxSecondaryRetries   = 3
+	defaultSecondaryDelay = time.Minute
+)
+
+// Priority decides whether a query may be deferred once the budget runs low
+type Priority int
+
+const (
+	HIGH Priority = iota
+	LOW
+)
+
+// RateLimit is requested alongside every query
+type RateLimit struct {
+	Cost      githubql.Int
+	Remaining githubql.Int
+	ResetAt   githubql.DateTime
+}
+
+// DeferredError is returned instead of running a low priority query while the
+// remaining budget is below the threshold
+type DeferredError struct {
+	Remaining int
+	ResetAt   time.Time
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("query deferred, %d points remaining until %s", e.Remaining, e.ResetAt.Format(time.Kitchen))
+}
+
+// IsDeferred reports whether err is a deferred low priority query
+func IsDeferred(err error) bool {
+	_, ok := errors.Cause(err).(*DeferredError)
+	return ok
+}
+
+// Usage sums the points spent since the last reset
+type Usage struct {
+	Queries   int
+	Cost      int
+	Deferred  int
+	Remaining int
+	ResetAt   time.Time
+}
+
+func (u Usage) String() string {
+	if u.Queries == 0 {
+		return "GitHub: no queries made"
+	}
+	return fmt.Sprintf(
+		"GitHub: %d queries spent %d points, %d deferred, %d points remaining until %s",
+		u.Queries,
+		u.Cost,
+		u.Deferred,
+		u.Remaining,
+		u.ResetAt.Format(time.Kitchen),
+	)
+}
+
+type budget struct {
+	threshold int
+
+	mu    sync.Mutex
+	known bool // false until the first response reports the budget
+	usage Usage
+}
+
+// wait returns a DeferredError for low priority queries while the budget is
+// below the threshold, and blocks high priority ones once it is exhausted
+func (b *budget) wait(priority Priority) error {
+	b.mu.Lock()
+	known, remaining, resetAt := b.known, b.usage.Remaining, b.usage.ResetAt
+	b.mu.Unlock()
+
+	if !known || time.Now().After(resetAt) {
+		return nil
+	}
+	if priority == LOW && remaining < b.threshold {
+		b.mu.Lock()
+		b.usage.Deferred++
+		b.mu.Unlock()
+		return &DeferredError{remaining, resetAt}
+	}
+	if remaining < minRemaining {
+		pause := time.Until(resetAt)
+		fmt.Printf("\tGitHub rate limit nearly spent, pausing for %s\n", pause)
+		time.Sleep(pause)
+	}
+	return nil
+}
+
+func (b *budget) record(rateLimit RateLimit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.known = true
+	b.usage.Queries++
+	b.usage.Cost += int(rateLimit.Cost)
+	b.usage.Remaining = int(rateLimit.Remaining)
+	b.usage.ResetAt = rateLimit.ResetAt.Time
+}
+
+// spend records points spent by a request that doesn't report the RateLimit
+func (b *budget) spend(cost int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.usage.Queries++
+	b.usage.Cost += cost
+	if b.known {
+		b.usage.Remaining -= cost
+	}
+}
+
+// query runs a query through the budget, recording the RateLimit field
+// every query struct carries
+func (c *Client) query(q interface{}, variables map[string]interface{}, priority Priority) error {
+	if err := c.budget.wait(priority); err != nil {
+		return err
+	}
+	if err := c.githubql.Query(context.Background(), q, variables); err != nil {
+		return err
+	}
+
+	if field := reflect.ValueOf(q).Elem().FieldByName("RateLimit"); field.IsValid() {
+		if rateLimit, ok := field.Interface().(RateLimit); ok {
+			c.budget.record(rateLimit)
+		}
+	}
+	return nil
+}
+
+// mutate runs a mutation through the budget. Mutations can't request the
+// RateLimit, so they are counted at mutationCost points. They are made on
+// behalf of trello changes and never deferred.
+func (c *Client) mutate(m interface{}, input githubql.Input) error {
+	if err := c.budget.wait(HIGH); err != nil {
+		return err
+	}
+	if err := c.githubql.Mutate(context.Background(), m, input, nil); err != nil {
+		return err
+	}
+	c.budget.spend(mutationCost)
+	return nil
+}
+
+// Usage returns the points spent since the last call to ResetUsage
+func (c *Client) Usage() Usage {
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+	return c.budget.usage
+}
+
+// ResetUsage starts a new count of spent points, keeping the known budget
+func (c *Client) ResetUsage() {
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+	c.budget.usage.Queries = 0
+	c.budget.usage.Cost = 0
+	c.budget.usage.Deferred = 0
+}
+
+// secondaryLimitTransport retries requests rejected by GitHub's secondary
+// rate limits or abuse detection after the delay GitHub asks for. Rejected
+// requests were never applied so mutations are retried too.
+type secondaryLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *secondaryLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req.WithContext(req.Context())
+		if body != nil {
+			attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil || attempt >= maxSecondaryRetries {
+			return resp, err
+		}
+
+		delay, limited := secondaryLimitDelay(resp)
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+		fmt.Printf("\t[WARNING] GitHub secondary rate limit hit, retrying in %s\n", delay)
+		time.Sleep(delay)
+	}
+}
+
+// secondaryLimitDelay reports whether resp is a secondary rate limit or abuse
+// detection response, restoring its body for the caller otherwise
+func secondaryLimitDelay(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	text := strings.ToLower(string(body))
+	retryAfter := resp.Header.Get("Retry-After")
+	if len(retryAfter) == 0 && !strings.Contains(text, "secondary rate limit") && !strings.Contains(text, "abuse") {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return defaultSecondaryDelay, true
+}
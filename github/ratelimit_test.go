@@ -0,0 +1,96 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rateLimitJSON reports remaining points until resetAt
+func rateLimitJSON(remaining int, resetAt time.Time) string {
+	return fmt.Sprintf(`{"cost": 1, "remaining": %d, "resetAt": %q}`, remaining, resetAt.UTC().Format(time.RFC3339))
+}
+
+func singleIssueSearch(after string) string {
+	return searchJSON("", issueJSON("issue", ""))
+}
+
+func TestLowPriorityDeferred(t *testing.T) {
+	fake := &graphQLServer{
+		search:    singleIssueSearch,
+		rateLimit: rateLimitJSON(100, time.Now().Add(time.Hour)),
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := newTestClient(server, Config{RateLimitThreshold: 500})
+	// high priority queries run below the threshold
+	if _, err := c.Issues.Assigned(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Issues.Mentioned(time.Time{})
+	if !IsDeferred(err) {
+		t.Fatalf("expected the low priority search to be deferred, got %v", err)
+	}
+	if fake.searches != 1 {
+		t.Errorf("expected the deferred search not to be sent, got %d searches", fake.searches)
+	}
+	if usage := c.Usage(); usage.Deferred != 1 || usage.Remaining != 100 {
+		t.Errorf("expected a deferred query to be counted, got %+v", usage)
+	}
+}
+
+func TestHighPriorityWaitsForReset(t *testing.T) {
+	resetAt := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	fake := &graphQLServer{
+		search:    singleIssueSearch,
+		rateLimit: rateLimitJSON(minRemaining-1, resetAt),
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := newTestClient(server, Config{})
+	if _, err := c.Issues.Assigned(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Issues.Assigned(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if now := time.Now(); now.Before(resetAt) {
+		t.Errorf("expected the second query to wait until %s, ran %s early", resetAt, resetAt.Sub(now))
+	}
+	if fake.searches != 2 {
+		t.Errorf("expected both searches to run, got %d", fake.searches)
+	}
+}
+
+func TestSecondaryLimitRetry(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			fake := &graphQLServer{
+				search:      singleIssueSearch,
+				limited:     1,
+				limitStatus: status,
+			}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			start := time.Now()
+			issues, err := newTestClient(server, Config{}).Issues.Assigned(time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != 1 {
+				t.Errorf("expected the retried search to return the issue, got %d", len(issues))
+			}
+			if fake.requests != 2 {
+				t.Errorf("expected a single retry, got %d requests", fake.requests)
+			}
+			if elapsed := time.Since(start); elapsed < time.Second {
+				t.Errorf("expected to wait for Retry-After, retried after %s", elapsed)
+			}
+		})
+	}
+}
@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
 	"github.com/luccacabra/github-to-trello/syncer"
 	githubSync "github.com/luccacabra/github-to-trello/syncer/github"
+	trelloSync "github.com/luccacabra/github-to-trello/syncer/trello"
 	"github.com/luccacabra/github-to-trello/trello"
+	"github.com/luccacabra/github-to-trello/webhook"
 
 	"github.com/luccacabra/github-to-trello/storage"
 	"github.com/spf13/viper"
@@ -18,16 +23,64 @@ import (
 
 var (
 	configFile = kingpin.Flag("config.file", "github-to-trello configuration file.").Default("github-to-trello.yaml").String()
+	stateFile  = kingpin.Flag("state.file", "github-to-trello state database file (overrides 'state_file').").String()
+	resetState = kingpin.Flag("reset-state", "Discard all stored issue and card state before syncing.").Bool()
+	dryRun     = kingpin.Flag("dry-run", "Print the Trello changes a sync would make without making them.").Bool()
+	planOutput = kingpin.Flag("plan.output", "Write the dry run plan as JSON to this file ('-' for stdout).").String()
+
+	syncCommand = kingpin.Command("sync", "Run a single sync.").Default()
+
+	watchCommand  = kingpin.Command("watch", "Keep syncing on an interval until interrupted.")
+	watchInterval = watchCommand.Flag("interval", "Time between syncs (overrides 'sync_interval').").Duration()
+	watchJitter   = watchCommand.Flag("jitter", "Maximum random delay added to each interval (overrides 'sync_jitter').").Duration()
+	watchRefresh  = watchCommand.Flag("refresh", "Time between trello list and label reloads (overrides 'trello_refresh_interval').").Duration()
+
+	githubWebhookCommand = kingpin.Command("github-webhook", "Serve a GitHub webhook endpoint that syncs the affected issue or pull request.")
+	githubWebhookListen  = githubWebhookCommand.Flag("listen", "Address to listen on.").Default(":8080").String()
+	githubWebhookPath    = githubWebhookCommand.Flag("path", "Path to serve the webhook on.").Default("/github").String()
+
+	reconcileCommand = kingpin.Command("reconcile", "Restore stored issues, cards and comment mappings from the synced cards on the trello board.")
+
+	initBoardCommand = kingpin.Command("init-board", "Create the trello lists and labels the configuration refers to that are missing from the board.")
+
+	rebuildCardsCommand = kingpin.Command("rebuild-cards", "Rebuild the stored card mappings from the card identities on the trello board.")
+
+	trelloWebhookCommand     = kingpin.Command("trello-webhook", "Register a trello board webhook and serve its endpoint, syncing card changes back to GitHub.")
+	trelloWebhookListen      = trelloWebhookCommand.Flag("listen", "Address to listen on.").Default(":8081").String()
+	trelloWebhookPath        = trelloWebhookCommand.Flag("path", "Path to serve the webhook on.").Default("/trello").String()
+	trelloWebhookCallbackURL = trelloWebhookCommand.Flag("callback-url", "Public URL trello delivers the webhook to.").Required().String()
 )
 
+type app struct {
+	trello  *trello.Client
+	github  *github.Client
+	storage *storage.Storage
+
+	issues       itemSyncer
+	pullRequests itemSyncer
+	// twoWay is nil unless 'two_way' is configured
+	twoWay twoWaySyncer
+}
+
+type itemSyncer interface {
+	syncer.Syncer
+	syncer.ItemSyncer
+}
+
+type twoWaySyncer interface {
+	syncer.Syncer
+	webhook.TrelloSubscriber
+}
+
 func main() {
 	// pls don't store secrets in config
 	ghAPIToken := viper.GetString("GH_APITOKEN")
 	trelloKey := viper.GetString("TRELLO_KEY")
 	trelloToken := viper.GetString("TRELLO_TOKEN")
+	trelloSecret := viper.GetString("TRELLO_SECRET")
 
 	// load config file
-	kingpin.Parse()
+	command := kingpin.Parse()
 	configFileBaseName := filepath.Base(*configFile)
 
 	viper.SetConfigName(strings.TrimSuffix(configFileBaseName, filepath.Ext(configFileBaseName)))
@@ -38,13 +91,27 @@ func main() {
 		panic(fmt.Errorf("Fatal error config file: %s \n", err))
 	}
 
+	templates := syncer.Templates{}
+	if err = viper.UnmarshalKey("templates", &templates); err != nil {
+		log.Fatalf("Unable to load templates: %s", err)
+	}
+	renderer, err := syncer.NewRenderer(templates)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	members := trello.MemberConfig{}
+	if err = viper.UnmarshalKey("trello_members", &members); err != nil {
+		log.Fatalf("Unable to load trello members: %s", err)
+	}
+
 	trelloClient := trello.NewClient(
 		trelloKey,
 		trelloToken,
 		trello.ClientConfig{
-			BoardName:     viper.GetString("trello_board_name"),
-			LabelCardName: viper.GetString("trello_label_card_name"),
-			LabelMap:      viper.GetStringMapString("trello_label_map"),
+			BoardName: viper.GetString("trello_board_name"),
+			Members:   members,
+			DryRun:    *dryRun,
 		},
 	)
 
@@ -53,17 +120,181 @@ func main() {
 		github.Config{
 			OrgName:  viper.GetString("github_org_name"),
 			UserName: viper.GetString("github_user_name"),
+			PageSize: viper.GetInt("github_page_size"),
+			MaxPages: viper.GetInt("github_max_pages"),
+
+			RateLimitThreshold: viper.GetInt("github_rate_limit_threshold"),
 		},
 	)
 
-	db := storage.Init()
+	if len(*stateFile) == 0 {
+		*stateFile = viper.GetString("state_file")
+	}
+	db := storage.Init(storage.Config{
+		Path:    *stateFile,
+		Reset:   *resetState,
+		Scratch: *dryRun,
+	})
 	defer db.Close()
 
-	conf := &syncer.Config{}
-	err = viper.UnmarshalKey("config", conf)
-
-	issueSyncer := githubSync.NewIssueSyncer(ghClient, trelloClient, db, conf.Issue)
-	if err = issueSyncer.Sync(); err != nil {
+	conf, err := resolver.Load(viper.GetViper())
+	if err != nil {
 		log.Fatal(err)
 	}
+	actionResolver := resolver.New(conf)
+	sweepInterval := viper.GetDuration("github_sweep_interval")
+
+	a := &app{
+		trello:       trelloClient,
+		github:       ghClient,
+		storage:      db,
+		issues:       githubSync.NewIssueSyncer(ghClient, trelloClient, db, actionResolver, renderer, sweepInterval),
+		pullRequests: githubSync.NewPullRequestSyncer(ghClient, trelloClient, db, actionResolver, renderer),
+	}
+
+	twoWayConf := trelloSync.Config{}
+	if err = viper.UnmarshalKey("two_way", &twoWayConf); err != nil {
+		log.Fatalf("Unable to load two way sync config: %s", err)
+	}
+	if twoWayConf.Enabled() {
+		a.twoWay = trelloSync.NewCardSyncer(ghClient, trelloClient, db, twoWayConf)
+	}
+
+	// init-board creates whatever the board is missing
+	if command != initBoardCommand.FullCommand() {
+		if err = validateBoard(trelloClient, actionResolver, twoWayConf); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch command {
+	case syncCommand.FullCommand():
+		if err = a.sync(); err != nil {
+			log.Fatal(err)
+		}
+	case watchCommand.FullCommand():
+		a.watch(loadWatchConfig())
+	case githubWebhookCommand.FullCommand():
+		if err = a.serveGitHubWebhook(viper.GetString("GH_WEBHOOK_SECRET")); err != nil {
+			log.Fatal(err)
+		}
+	case reconcileCommand.FullCommand():
+		report, err := githubSync.NewReconciler(ghClient, trelloClient, db, actionResolver, renderer).Reconcile()
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.Print(os.Stdout)
+	case initBoardCommand.FullCommand():
+		if err = a.initBoard(actionResolver, twoWayConf); err != nil {
+			log.Fatal(err)
+		}
+	case rebuildCardsCommand.FullCommand():
+		if err = a.rebuildCards(); err != nil {
+			log.Fatal(err)
+		}
+	case trelloWebhookCommand.FullCommand():
+		if err = a.serveTrelloWebhook(trelloSecret); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// sync runs every syncer once, printing the plan of a dry run
+func (a *app) sync() error {
+	syncers := []syncer.Syncer{a.issues, a.pullRequests}
+	// pick up trello changes before the GitHub sync moves any cards
+	if a.twoWay != nil {
+		syncers = append([]syncer.Syncer{a.twoWay}, syncers...)
+	}
+	for _, s := range syncers {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(a.github.Usage())
+	a.github.ResetUsage()
+
+	return a.flushPlan()
+}
+
+// flushPlan prints and discards the operations planned so far, it does
+// nothing unless this is a dry run
+func (a *app) flushPlan() error {
+	plan := a.trello.Plan()
+	if plan == nil {
+		return nil
+	}
+	defer plan.Reset()
+	return writePlan(plan, *planOutput)
+}
+
+// flushEventPlan flushes the plan of a single webhook event, logging failures
+// as the server keeps running
+func (a *app) flushEventPlan() {
+	if err := a.flushPlan(); err != nil {
+		log.Printf("[ERROR] %s", err)
+	}
+}
+
+func writePlan(plan *trello.Plan, output string) error {
+	plan.Print(os.Stdout)
+
+	switch output {
+	case "":
+		return nil
+	case "-":
+		return plan.WriteJSON(os.Stdout)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("Unable to write plan: %s", err)
+	}
+	defer f.Close()
+	return plan.WriteJSON(f)
+}
+
+func (a *app) serveGitHubWebhook(secret string) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("Must specify 'GH_WEBHOOK_SECRET' to verify GitHub webhooks")
+	}
+
+	handler := webhook.NewGitHubHandler(webhook.GitHubConfig{
+		Secret:       secret,
+		Issues:       a.issues,
+		PullRequests: a.pullRequests,
+		Synced:       a.flushEventPlan,
+	})
+	defer handler.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle(*githubWebhookPath, handler)
+	return serve(*githubWebhookListen, mux, nil)
+}
+
+// serveTrelloWebhook registers the board webhook once the endpoint is up, as
+// trello checks the callback URL on registration
+func (a *app) serveTrelloWebhook(secret string) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("Must specify 'TRELLO_SECRET' to verify trello webhooks")
+	}
+	if a.twoWay == nil {
+		return fmt.Errorf("Must configure 'two_way' to handle trello webhooks")
+	}
+
+	handler := webhook.NewTrelloHandler(webhook.TrelloConfig{
+		Secret:      secret,
+		CallbackURL: *trelloWebhookCallbackURL,
+		Handled:     a.flushEventPlan,
+	})
+	handler.Subscribe(a.twoWay)
+	defer handler.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle(*trelloWebhookPath, handler)
+	return serve(*trelloWebhookListen, mux, func() error {
+		_, err := a.trello.RegisterWebhook(*trelloWebhookCallbackURL)
+		return err
+	})
 }
@@ -0,0 +1,113 @@
+/*
+Converts GitHub flavoured markdown into markdown trello renders.
+
+References and mentions become absolute links, relative links and images are
+resolved against the repository, HTML comments are dropped, <details> blocks
+are flattened, task list items get check boxes and tables are laid out in a
+code block. Code blocks and code spans are left untouched.
+*/
+
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const gitHubURL = "https://github.com"
+
+// Repository is the repository references and relative links are resolved in
+type Repository struct {
+	Owner string
+	Name  string
+}
+
+var repositoryURL = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/#?]+)`)
+
+// RepositoryFromURL returns the repository of a GitHub issue, pull request or
+// comment URL
+func RepositoryFromURL(url string) Repository {
+	match := repositoryURL.FindStringSubmatch(url)
+	if match == nil {
+		return Repository{}
+	}
+	return Repository{Owner: match[1], Name: match[2]}
+}
+
+func (r Repository) url(path string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", gitHubURL, r.Owner, r.Name, path)
+}
+
+var (
+	fenceOpen   = regexp.MustCompile("^\\s*(```+|~~~+)")
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	summary     = regexp.MustCompile(`(?is)<summary[^>]*>\s*(.*?)\s*</summary>\s*`)
+	detailsTag  = regexp.MustCompile(`(?i)</?details[^>]*>`)
+	inlineTag   = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	taskItem    = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+)\[([ xX])\]\s`)
+)
+
+// Convert rewrites text, written in repo, into markdown trello renders
+func Convert(text string, repo Repository) string {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+
+	converted := []string{}
+	prose := []string{}
+	fence := ""
+	for _, line := range lines {
+		if len(fence) > 0 {
+			converted = append(converted, line)
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if match := fenceOpen.FindStringSubmatch(line); match != nil {
+			converted = append(converted, convertProse(prose, repo)...)
+			prose = prose[:0]
+			converted = append(converted, line)
+			fence = match[1]
+			continue
+		}
+		prose = append(prose, line)
+	}
+	converted = append(converted, convertProse(prose, repo)...)
+
+	return strings.Join(converted, "\n")
+}
+
+// convertProse converts lines outside of code blocks
+func convertProse(lines []string, repo Repository) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	text := strings.Join(lines, "\n")
+	text = htmlComment.ReplaceAllString(text, "")
+	// the summary is bolded instead, so tags styling it are dropped
+	text = summary.ReplaceAllStringFunc(text, func(tag string) string {
+		return "**" + inlineTag.ReplaceAllString(summary.FindStringSubmatch(tag)[1], "") + "**\n\n"
+	})
+	text = detailsTag.ReplaceAllString(text, "")
+	text = convertImageTags(text)
+	lines = strings.Split(text, "\n")
+
+	converted := []string{}
+	for idx := 0; idx < len(lines); idx++ {
+		if rows := tableRows(lines[idx:]); rows > 0 {
+			converted = append(converted, renderTable(lines[idx:idx+rows])...)
+			idx += rows - 1
+			continue
+		}
+		line := taskItem.ReplaceAllStringFunc(lines[idx], func(item string) string {
+			match := taskItem.FindStringSubmatch(item)
+			if match[2] == " " {
+				return match[1] + "☐ "
+			}
+			return match[1] + "☑ "
+		})
+		converted = append(converted, convertLine(line, repo))
+	}
+	return converted
+}
@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current output")
+
+// TestConvert converts every testdata/*.md file written in octo/widgets and
+// compares the result with its .golden file
+func TestConvert(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no test inputs found")
+	}
+
+	repo := Repository{Owner: "octo", Name: "widgets"}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".md")
+		t.Run(name, func(t *testing.T) {
+			text, err := ioutil.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			converted := Convert(string(text), repo)
+
+			golden := strings.TrimSuffix(input, ".md") + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(converted), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if converted != string(expected) {
+				t.Errorf("converted %s differs from %s:\n%s", input, golden, converted)
+			}
+		})
+	}
+}
+
+func TestConvertWithoutRepository(t *testing.T) {
+	text := "Fixes #12, see [docs](docs/README.md)"
+	if converted := Convert(text, Repository{}); converted != text {
+		t.Errorf("expected references to be left alone, got %q", converted)
+	}
+}
+
+func TestRepositoryFromURL(t *testing.T) {
+	repo := RepositoryFromURL("https://github.com/octo/widgets/issues/1#issuecomment-2")
+	if repo != (Repository{Owner: "octo", Name: "widgets"}) {
+		t.Errorf("unexpected repository %+v", repo)
+	}
+}
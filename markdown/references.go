@@ -0,0 +1,146 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// protected spans are never searched for references: code spans, links,
+	// images and URLs
+	protected = regexp.MustCompile("`[^`]*`|!?\\[[^\\]]*\\]\\([^)]*\\)|<https?://[^>]+>|https?://[^\\s<>)]+")
+
+	// reference matches issue and commit references and mentions. The token
+	// is group 1, the character before it is not part of it.
+	reference = regexp.MustCompile(
+		`(?:^|[^\w/&@.-])(` +
+			`([\w.-]+/[\w.-]+)?#(\d+)` +
+			`|([\w.-]+/[\w.-]+)@([0-9a-f]{7,40})` +
+			`|@([A-Za-z0-9][A-Za-z0-9-]{0,38})(/[\w.-]+)?` +
+			`|([0-9a-f]{7,40})` +
+			`)\b`,
+	)
+
+	hasDigit  = regexp.MustCompile(`[0-9]`)
+	hasLetter = regexp.MustCompile(`[a-f]`)
+
+	markdownLink = regexp.MustCompile(`^(!?)(\[[^\]]*\]\()([^)\s]*)(.*)$`)
+	urlScheme    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+	imageTag  = regexp.MustCompile(`(?i)<img\s[^>]*>`)
+	imageAttr = regexp.MustCompile(`(?i)\b(src|alt)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// convertLine links the references in a line, resolving the relative links
+// and images it contains
+func convertLine(line string, repo Repository) string {
+	converted := ""
+	last := 0
+	for _, span := range protected.FindAllStringIndex(line, -1) {
+		converted += convertReferences(line[last:span[0]], repo)
+		converted += resolveLink(line[span[0]:span[1]], repo)
+		last = span[1]
+	}
+	return converted + convertReferences(line[last:], repo)
+}
+
+func convertReferences(text string, repo Repository) string {
+	converted := ""
+	last := 0
+	for _, match := range reference.FindAllStringSubmatchIndex(text, -1) {
+		group := func(n int) string {
+			if match[2*n] < 0 {
+				return ""
+			}
+			return text[match[2*n]:match[2*n+1]]
+		}
+
+		token := group(1)
+		link := ""
+		switch {
+		case len(group(3)) > 0:
+			link = repositoryFor(group(2), repo).url("issues/" + group(3))
+		case len(group(5)) > 0:
+			link = repositoryFor(group(4), repo).url("commit/" + group(5))
+			token = fmt.Sprintf("%s@%s", group(4), shortSHA(group(5)))
+		case len(group(6)) > 0 && len(group(7)) > 0:
+			link = fmt.Sprintf("%s/orgs/%s/teams%s", gitHubURL, group(6), group(7))
+		case len(group(6)) > 0:
+			link = fmt.Sprintf("%s/%s", gitHubURL, group(6))
+		case isSHA(group(8)):
+			link = repo.url("commit/" + group(8))
+			token = shortSHA(group(8))
+		}
+		if len(link) == 0 || len(repo.Owner) == 0 {
+			continue
+		}
+
+		converted += text[last:match[2]] + fmt.Sprintf("[%s](%s)", token, link)
+		last = match[3]
+	}
+	return converted + text[last:]
+}
+
+// repositoryFor parses an owner/name reference, repo when there is none
+func repositoryFor(ownerName string, repo Repository) Repository {
+	parts := strings.SplitN(ownerName, "/", 2)
+	if len(parts) != 2 {
+		return repo
+	}
+	return Repository{Owner: parts[0], Name: parts[1]}
+}
+
+// isSHA tells commit SHAs apart from words and numbers made of hex digits
+func isSHA(token string) bool {
+	return len(token) > 0 && hasDigit.MatchString(token) && hasLetter.MatchString(token)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// resolveLink makes the URL of a relative markdown link or image absolute,
+// other protected spans are returned as they are
+func resolveLink(span string, repo Repository) string {
+	match := markdownLink.FindStringSubmatch(span)
+	if match == nil {
+		return span
+	}
+	return match[1] + match[2] + resolveURL(match[3], match[1] == "!", repo) + match[4]
+}
+
+// resolveURL resolves url against the repository's default branch, images
+// point at the raw file
+func resolveURL(url string, image bool, repo Repository) string {
+	switch {
+	case len(url) == 0, strings.HasPrefix(url, "#"), urlScheme.MatchString(url):
+		return url
+	case strings.HasPrefix(url, "//"):
+		return "https:" + url
+	case strings.HasPrefix(url, "/"):
+		return gitHubURL + url
+	case len(repo.Owner) == 0:
+		return url
+	case image:
+		return repo.url("raw/HEAD/" + strings.TrimPrefix(url, "./"))
+	}
+	return repo.url("blob/HEAD/" + strings.TrimPrefix(url, "./"))
+}
+
+// convertImageTags rewrites HTML images as markdown images
+func convertImageTags(text string) string {
+	return imageTag.ReplaceAllStringFunc(text, func(tag string) string {
+		attrs := map[string]string{}
+		for _, attr := range imageAttr.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(attr[1])] = attr[2] + attr[3]
+		}
+		if len(attrs["src"]) == 0 {
+			return tag
+		}
+		return fmt.Sprintf("![%s](%s)", attrs["alt"], attrs["src"])
+	})
+}
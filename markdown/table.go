@@ -0,0 +1,97 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var tableDelimiter = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// tableRows returns the number of lines of the table starting lines, 0 when
+// they don't start with one. A table is a header row followed by a delimiter
+// row and any number of body rows.
+func tableRows(lines []string) int {
+	if len(lines) < 2 ||
+		!strings.Contains(lines[0], "|") ||
+		!strings.Contains(lines[1], "|") ||
+		!tableDelimiter.MatchString(lines[1]) {
+		return 0
+	}
+
+	rows := 2
+	for rows < len(lines) && strings.Contains(lines[rows], "|") && len(strings.TrimSpace(lines[rows])) > 0 {
+		rows++
+	}
+	return rows
+}
+
+// renderTable lays a table out in a code block, trello has no tables
+func renderTable(lines []string) []string {
+	rows := [][]string{}
+	widths := []int{}
+	for idx, line := range lines {
+		// the delimiter row is redrawn below the header
+		if idx == 1 {
+			continue
+		}
+		cells := tableCells(line)
+		for col, cell := range cells {
+			if col == len(widths) {
+				widths = append(widths, 0)
+			}
+			if width := utf8.RuneCountInString(cell); width > widths[col] {
+				widths[col] = width
+			}
+		}
+		rows = append(rows, cells)
+	}
+
+	rendered := []string{"```"}
+	for idx, cells := range rows {
+		padded := make([]string, len(widths))
+		for col, width := range widths {
+			cell := ""
+			if col < len(cells) {
+				cell = cells[col]
+			}
+			padded[col] = cell + strings.Repeat(" ", width-utf8.RuneCountInString(cell))
+		}
+		rendered = append(rendered, strings.TrimRight(strings.Join(padded, " | "), " "))
+
+		if idx == 0 {
+			delimiter := make([]string, len(widths))
+			for col, width := range widths {
+				delimiter[col] = strings.Repeat("-", width)
+			}
+			rendered = append(rendered, strings.Join(delimiter, "-+-"))
+		}
+	}
+	return append(rendered, "```")
+}
+
+// tableCells splits a table row into its trimmed cells, escaped pipes are
+// kept in the cell
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+
+	cells := []string{}
+	cell := []byte{}
+	for idx := 0; idx < len(line); idx++ {
+		switch {
+		case line[idx] == '\\' && idx+1 < len(line) && line[idx+1] == '|':
+			cell = append(cell, '|')
+			idx++
+		case line[idx] == '|':
+			cells = append(cells, strings.TrimSpace(string(cell)))
+			cell = cell[:0]
+		default:
+			cell = append(cell, line[idx])
+		}
+	}
+	return append(cells, strings.TrimSpace(string(cell)))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/luccacabra/github-to-trello/storage"
+
+	"github.com/pkg/errors"
+)
+
+// rebuildCards replaces the stored card instances with the identified cards on
+// the board, archived ones included. Tracked cards created before identities
+// existed are identified first. Instances of cards still on the board keep
+// their row, so their comment mappings survive.
+func (a *app) rebuildCards() error {
+	stored, err := a.storage.FindAllCards()
+	if err != nil {
+		return errors.Wrap(err, "Unable to rebuild cards")
+	}
+	_, unidentified, err := a.trello.FindIdentifiedCards()
+	if err != nil {
+		return errors.Wrap(err, "Unable to rebuild cards")
+	}
+
+	needsIdentity := map[string]bool{}
+	for _, trelloCardId := range unidentified {
+		needsIdentity[trelloCardId] = true
+	}
+	for _, card := range stored {
+		if !needsIdentity[card.TrelloCardId] || len(card.GitHubId) == 0 {
+			continue
+		}
+		issue, err := a.storage.GetIssue(card.IssueId)
+		if err != nil {
+			return errors.Wrap(err, "Unable to rebuild cards")
+		}
+		if issue == nil {
+			continue
+		}
+		fmt.Printf("Identifying card \"%s\"\n", card.Title)
+		if err := a.trello.NewCard(card).AttachIdentity(issue.URL); err != nil {
+			return errors.Wrap(err, "Unable to rebuild cards")
+		}
+	}
+
+	identified, unidentified, err := a.trello.FindIdentifiedCards()
+	if err != nil {
+		return errors.Wrap(err, "Unable to rebuild cards")
+	}
+
+	byTrelloId := map[string]*storage.Card{}
+	for _, card := range stored {
+		byTrelloId[card.TrelloCardId] = card
+	}
+	cards := []*storage.Card{}
+	for _, card := range identified {
+		if existing := byTrelloId[card.TrelloCardId]; existing != nil {
+			card.Id = existing.Id
+			card.Due = existing.Due
+		}
+		cards = append(cards, card)
+
+		issue, err := a.storage.FindIssue(card.GitHubId)
+		if err != nil {
+			return errors.Wrap(err, "Unable to rebuild cards")
+		}
+		// cards of untracked issues are adopted when the issue is next synced
+		if issue != nil {
+			card.IssueId = issue.Id
+		}
+	}
+
+	// tracked cards that couldn't be identified stay while they exist
+	for _, trelloCardId := range unidentified {
+		if existing := byTrelloId[trelloCardId]; existing != nil {
+			cards = append(cards, existing)
+		}
+	}
+
+	fmt.Printf("Rebuilding %d tracked cards from %d identified cards\n", len(stored), len(identified))
+	return a.storage.ReplaceCards(cards)
+}
@@ -0,0 +1,149 @@
+/* sync_actions configuration tree as documented in the README */
+
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+type Label struct {
+	Color string
+	Name  string
+}
+
+// Target is the set of lists and labels a single config layer applies
+type Target struct {
+	Labels []Label
+	Lists  []string
+}
+
+type Config struct {
+	Labels      []Label                 `mapstructure:"trello_labels"`
+	Lists       []string                `mapstructure:"trello_lists"`
+	SyncActions map[string]ActionConfig `mapstructure:"sync_actions"`
+
+	GitHubLabels GitHubLabelConfig `mapstructure:"github_labels"`
+	DueDates     DueDateConfig     `mapstructure:"due_dates"`
+}
+
+type ActionConfig struct {
+	Target `mapstructure:",squash"`
+	// Archive only applies to the close action
+	Archive    bool
+	IssueTypes map[string]IssueTypeConfig `mapstructure:"issue_types"`
+}
+
+type IssueTypeConfig struct {
+	Target           `mapstructure:",squash"`
+	UserRelationship UserRelationshipConfig `mapstructure:"user_relationship"`
+}
+
+type UserRelationshipConfig struct {
+	Assignee        Target
+	Author          Target
+	Mentioned       Target
+	ReviewRequested struct {
+		Team Target
+		User Target
+	} `mapstructure:"review_requested"`
+}
+
+// Load reads the resolver config from the root of the viper config
+func Load(v *viper.Viper) (*Config, error) {
+	config := &Config{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, errors.Wrap(err, "Error loading sync actions")
+	}
+	if err := config.validate(); err != nil {
+		return nil, errors.Wrap(err, "Error loading sync actions")
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	for action, actionConfig := range c.SyncActions {
+		if !Action(action).valid() {
+			return fmt.Errorf("unknown action \"sync_actions.%s\", expected one of %v", action, actions)
+		}
+		for itemType := range actionConfig.IssueTypes {
+			if !ItemType(itemType).valid() {
+				return fmt.Errorf(
+					"unknown issue type \"sync_actions.%s.issue_types.%s\", expected one of %v",
+					action,
+					itemType,
+					itemTypes,
+				)
+			}
+		}
+	}
+	if err := c.GitHubLabels.validate(); err != nil {
+		return err
+	}
+	return c.DueDates.validate()
+}
+
+// configTarget is a layer of the config with the config paths of its lists
+// and labels
+type configTarget struct {
+	Target
+	lists, labels string
+}
+
+func newConfigTarget(target Target, path string) configTarget {
+	return configTarget{Target: target, lists: path + ".lists", labels: path + ".labels"}
+}
+
+// targets returns every layer of the config, in the order of the README
+func (c *Config) targets() []configTarget {
+	targets := []configTarget{{
+		Target: Target{Labels: c.Labels, Lists: c.Lists},
+		lists:  "trello_lists",
+		labels: "trello_labels",
+	}}
+	for _, action := range actions {
+		actionConfig, ok := c.SyncActions[string(action)]
+		if !ok {
+			continue
+		}
+		actionPath := "sync_actions." + string(action)
+		targets = append(targets, newConfigTarget(actionConfig.Target, actionPath))
+		for _, itemType := range itemTypes {
+			typeConfig, ok := actionConfig.IssueTypes[string(itemType)]
+			if !ok {
+				continue
+			}
+			typePath := actionPath + ".issue_types." + string(itemType)
+			relationships := typeConfig.UserRelationship
+			relationshipPath := typePath + ".user_relationship"
+			targets = append(
+				targets,
+				newConfigTarget(typeConfig.Target, typePath),
+				newConfigTarget(relationships.Assignee, relationshipPath+".assignee"),
+				newConfigTarget(relationships.Author, relationshipPath+".author"),
+				newConfigTarget(relationships.Mentioned, relationshipPath+".mentioned"),
+				newConfigTarget(relationships.ReviewRequested.Team, relationshipPath+".review_requested.team"),
+				newConfigTarget(relationships.ReviewRequested.User, relationshipPath+".review_requested.user"),
+			)
+		}
+	}
+	return targets
+}
+
+func (u UserRelationshipConfig) target(relation Relation) Target {
+	switch relation {
+	case ASSIGNEE:
+		return u.Assignee
+	case AUTHOR:
+		return u.Author
+	case MENTIONED:
+		return u.Mentioned
+	case REVIEW_REQUESTED_TEAM:
+		return u.ReviewRequested.Team
+	case REVIEW_REQUESTED_USER:
+		return u.ReviewRequested.User
+	}
+	return Target{}
+}
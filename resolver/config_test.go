@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func loadYAML(t *testing.T, config string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatalf("invalid test config: %s", err)
+	}
+	return Load(v)
+}
+
+func TestLoad(t *testing.T) {
+	config, err := loadYAML(t, `
+trello_lists: [Inbox]
+trello_labels:
+  - name: synced
+sync_actions:
+  open:
+    issue_types:
+      issue:
+        user_relationship:
+          review_requested:
+            team:
+              lists: [Team]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := New(config).Resolve(ISSUE, OPEN, []Relation{REVIEW_REQUESTED_TEAM})
+	if len(result.Lists) != 1 || result.Lists[0] != "Team" {
+		t.Errorf("expected lists [Team], got %v", result.Lists)
+	}
+	if len(result.Labels) != 1 || result.Labels[0].Name != "synced" {
+		t.Errorf("expected labels [synced], got %v", result.Labels)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{
+			name: "unknown action",
+			config: `
+sync_actions:
+  reopen:
+    lists: [Inbox]
+`,
+			expected: `unknown action "sync_actions.reopen"`,
+		},
+		{
+			name: "unknown issue type",
+			config: `
+sync_actions:
+  open:
+    issue_types:
+      discussion:
+        lists: [Inbox]
+`,
+			expected: `unknown issue type "sync_actions.open.issue_types.discussion"`,
+		},
+		{
+			name: "label rule without matcher",
+			config: `
+github_labels:
+  map:
+    - trello: Bug
+`,
+			expected: `"github_labels.map[0]" must set exactly one of github, prefix or regex`,
+		},
+		{
+			name: "invalid label regex",
+			config: `
+github_labels:
+  map:
+    - regex: "("
+`,
+			expected: `invalid regex "github_labels.map[0].regex"`,
+		},
+		{
+			name: "due date regex without submatch",
+			config: `
+due_dates:
+  label: ^due$
+`,
+			expected: `regex "due_dates.label" must capture the due date`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadYAML(t, test.config)
+			if err == nil {
+				t.Fatalf("expected error containing %q", test.expected)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error containing %q, got %q", test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dueDateLayouts are the formats due dates in labels and bodies may use
+var dueDateLayouts = []string{"2006-01-02", time.RFC3339}
+
+// DueDateConfig picks the due date of a card
+type DueDateConfig struct {
+	// Milestone uses the due date of the item's milestone
+	Milestone bool
+	// Label and Field match GitHub labels and lines of the body, their first
+	// submatch is the due date. They take precedence over the milestone.
+	Label string
+	Field string
+
+	label *regexp.Regexp
+	field *regexp.Regexp
+}
+
+func (c *DueDateConfig) validate() error {
+	for _, pattern := range []struct {
+		path   string
+		text   string
+		target **regexp.Regexp
+	}{
+		{"due_dates.label", c.Label, &c.label},
+		{"due_dates.field", c.Field, &c.field},
+	} {
+		if len(pattern.text) == 0 {
+			continue
+		}
+		regex, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return fmt.Errorf("invalid regex \"%s\": %s", pattern.path, err)
+		}
+		if regex.NumSubexp() < 1 {
+			return fmt.Errorf("regex \"%s\" must capture the due date", pattern.path)
+		}
+		*pattern.target = regex
+	}
+	return nil
+}
+
+// DueDate returns the due date of an item from its GitHub labels, body or
+// milestone due date, zero if it has none
+func (r *Resolver) DueDate(labels []Label, body string, milestoneDueOn time.Time) time.Time {
+	config := r.config.DueDates
+
+	if config.label != nil {
+		for _, label := range labels {
+			if due, ok := matchDueDate(config.label, label.Name); ok {
+				return due
+			}
+		}
+	}
+	if config.field != nil {
+		for _, line := range strings.Split(body, "\n") {
+			if due, ok := matchDueDate(config.field, strings.TrimSpace(line)); ok {
+				return due
+			}
+		}
+	}
+	if config.Milestone {
+		return milestoneDueOn
+	}
+	return time.Time{}
+}
+
+func matchDueDate(regex *regexp.Regexp, text string) (time.Time, bool) {
+	match := regex.FindStringSubmatch(text)
+	if match == nil {
+		return time.Time{}, false
+	}
+	for _, layout := range dueDateLayouts {
+		if due, err := time.Parse(layout, strings.TrimSpace(match[1])); err == nil {
+			return due, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,133 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GitHubLabelConfig maps the labels of GitHub items to trello labels
+type GitHubLabelConfig struct {
+	// Map is tried in order, the first matching rule wins
+	Map []GitHubLabelRule
+	// Passthrough keeps labels no rule matches under their GitHub name,
+	// otherwise they are dropped
+	Passthrough bool
+	// Create adds labels missing from the board with the trello colour
+	// closest to the GitHub one
+	Create bool
+	// Milestone adds a label named after the item's milestone
+	Milestone bool
+}
+
+// GitHubLabelRule matches GitHub labels by exact name, prefix or regular
+// expression - exactly one of GitHub, Prefix or Regex is set
+type GitHubLabelRule struct {
+	GitHub string `mapstructure:"github"`
+	Prefix string
+	Regex  string
+	// Trello names the trello label, regex rules may refer to submatches
+	// ($1). When empty the GitHub name is kept, less the prefix for prefix rules.
+	Trello string
+	// Skip drops matching labels
+	Skip bool
+
+	regex *regexp.Regexp
+}
+
+func (c *GitHubLabelConfig) validate() error {
+	for idx := range c.Map {
+		rule := &c.Map[idx]
+
+		matchers := 0
+		for _, matcher := range []string{rule.GitHub, rule.Prefix, rule.Regex} {
+			if len(matcher) > 0 {
+				matchers++
+			}
+		}
+		if matchers != 1 {
+			return fmt.Errorf("\"github_labels.map[%d]\" must set exactly one of github, prefix or regex", idx)
+		}
+
+		if len(rule.Regex) > 0 {
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return fmt.Errorf("invalid regex \"github_labels.map[%d].regex\": %s", idx, err)
+			}
+			rule.regex = regex
+		}
+	}
+	return nil
+}
+
+// apply returns the trello label name for a GitHub label, false when the
+// rule doesn't match
+func (r GitHubLabelRule) apply(name string) (string, bool) {
+	switch {
+	case len(r.GitHub) > 0:
+		if name != r.GitHub {
+			return "", false
+		}
+		return orDefault(r.Trello, name), true
+	case len(r.Prefix) > 0:
+		if !strings.HasPrefix(name, r.Prefix) {
+			return "", false
+		}
+		return orDefault(r.Trello, strings.TrimPrefix(name, r.Prefix)), true
+	case r.regex != nil:
+		match := r.regex.FindStringSubmatchIndex(name)
+		if match == nil {
+			return "", false
+		}
+		if len(r.Trello) == 0 {
+			return name, true
+		}
+		return string(r.regex.ExpandString(nil, r.Trello, name, match)), true
+	}
+	return "", false
+}
+
+func orDefault(value, fallback string) string {
+	if len(value) == 0 {
+		return fallback
+	}
+	return value
+}
+
+// GitHubLabels maps the labels of a GitHub item to trello labels, followed by
+// the milestone's label when configured. Label colours are passed through as
+// GitHub hex colours.
+func (r *Resolver) GitHubLabels(labels []Label, milestone string) []Label {
+	config := r.config.GitHubLabels
+
+	mapped := []Label{}
+	seen := map[string]bool{}
+	for _, label := range labels {
+		name, matched := "", false
+		for _, rule := range config.Map {
+			if name, matched = rule.apply(label.Name); matched {
+				if rule.Skip {
+					name = ""
+				}
+				break
+			}
+		}
+		if !matched && config.Passthrough {
+			name = label.Name
+		}
+		if len(name) > 0 && !seen[name] {
+			seen[name] = true
+			mapped = append(mapped, Label{Name: name, Color: label.Color})
+		}
+	}
+	if config.Milestone && len(milestone) > 0 && !seen[milestone] {
+		mapped = append(mapped, Label{Name: milestone})
+	}
+	return mapped
+}
+
+// CreateGitHubLabels reports whether labels mapped from GitHub that are missing
+// from the board are created
+func (r *Resolver) CreateGitHubLabels() bool {
+	return r.config.GitHubLabels.Create
+}
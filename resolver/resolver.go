@@ -0,0 +1,227 @@
+/*
+Resolves the effective lists and labels for a synced item.
+
+Layers are applied in order of precedence
+	base < issue_type#type < (card_action#action | user_relation#relation)
+where card actions and user relations share the top layer.
+
+Lists are overridden - the highest layer that names any lists wins, and the
+lists of the top layer peers are combined. The base lists only apply to open.
+Labels are additive - every layer contributes its labels, duplicates are
+dropped.
+*/
+
+package resolver
+
+import "fmt"
+
+type ItemType string
+
+const (
+	ISSUE        ItemType = "issue"
+	PULL_REQUEST          = "pull_request"
+)
+
+var itemTypes = []ItemType{ISSUE, PULL_REQUEST}
+
+func (t ItemType) valid() bool {
+	for _, itemType := range itemTypes {
+		if t == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+type Action string
+
+const (
+	OPEN   Action = "open"
+	UPDATE        = "update"
+	CLOSE         = "close"
+)
+
+var actions = []Action{OPEN, UPDATE, CLOSE}
+
+func (a Action) valid() bool {
+	for _, action := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+type Relation string
+
+const (
+	ASSIGNEE              Relation = "assignee"
+	AUTHOR                         = "author"
+	MENTIONED                      = "mentioned"
+	REVIEW_REQUESTED_TEAM          = "review_requested.team"
+	REVIEW_REQUESTED_USER          = "review_requested.user"
+)
+
+type Result struct {
+	Archive bool
+	Labels  []Label
+	Lists   []string
+}
+
+type Resolver struct {
+	config *Config
+}
+
+func New(config *Config) *Resolver {
+	return &Resolver{
+		config: config,
+	}
+}
+
+// Resolve computes the lists and labels for an item of the given type when
+// action is applied to it, given every relation the user has to the item
+func (r *Resolver) Resolve(itemType ItemType, action Action, relations []Relation) Result {
+	actionConfig := r.config.SyncActions[string(action)]
+	typeConfig := actionConfig.IssueTypes[string(itemType)]
+
+	top := []Target{actionConfig.Target}
+	for _, relation := range relations {
+		top = append(top, typeConfig.UserRelationship.target(relation))
+	}
+	base := Target{Labels: r.config.Labels}
+	// the base lists only place new cards, updates must not move cards back
+	// to them
+	if action == OPEN {
+		base.Lists = r.config.Lists
+	}
+	layers := [][]Target{
+		{base},
+		{typeConfig.Target},
+		top,
+	}
+
+	result := Result{
+		Archive: actionConfig.Archive,
+	}
+	for _, layer := range layers {
+		lists := []string{}
+		for _, target := range layer {
+			lists = appendLists(lists, target.Lists...)
+			result.Labels = appendLabels(result.Labels, target.Labels...)
+		}
+		if len(lists) > 0 {
+			result.Lists = lists
+		}
+	}
+	return result
+}
+
+// Lists returns every list the config names
+func (r *Resolver) Lists() []string {
+	lists := []string{}
+	for _, target := range r.config.targets() {
+		lists = appendLists(lists, target.Lists...)
+	}
+	return lists
+}
+
+// Labels returns every label the config names
+func (r *Resolver) Labels() []Label {
+	labels := []Label{}
+	for _, target := range r.config.targets() {
+		labels = appendLabels(labels, target.Labels...)
+	}
+	return labels
+}
+
+// ListReference is a list named by the config, Path locates it
+type ListReference struct {
+	Path string
+	Name string
+}
+
+// LabelReference is a label named by the config, Path locates it
+type LabelReference struct {
+	Path  string
+	Label Label
+}
+
+// ListReferences returns every list the config names with its config path
+func (r *Resolver) ListReferences() []ListReference {
+	references := []ListReference{}
+	for _, target := range r.config.targets() {
+		for idx, name := range target.Lists {
+			references = append(references, ListReference{
+				Path: fmt.Sprintf("%s[%d]", target.lists, idx),
+				Name: name,
+			})
+		}
+	}
+	return references
+}
+
+// LabelReferences returns every label the config names with its config path.
+// Labels mapped from GitHub only count when they are fixed names that aren't
+// created on demand.
+func (r *Resolver) LabelReferences() []LabelReference {
+	references := []LabelReference{}
+	for _, target := range r.config.targets() {
+		for idx, label := range target.Labels {
+			references = append(references, LabelReference{
+				Path:  fmt.Sprintf("%s[%d]", target.labels, idx),
+				Label: label,
+			})
+		}
+	}
+
+	gitHubLabels := r.config.GitHubLabels
+	if gitHubLabels.Create {
+		return references
+	}
+	for idx, rule := range gitHubLabels.Map {
+		if rule.Skip || len(rule.Trello) == 0 || len(rule.Regex) > 0 {
+			continue
+		}
+		references = append(references, LabelReference{
+			Path:  fmt.Sprintf("github_labels.map[%d].trello", idx),
+			Label: Label{Name: rule.Trello},
+		})
+	}
+	return references
+}
+
+func appendLists(lists []string, names ...string) []string {
+	for _, name := range names {
+		if !containsList(lists, name) {
+			lists = append(lists, name)
+		}
+	}
+	return lists
+}
+
+func containsList(lists []string, name string) bool {
+	for _, list := range lists {
+		if list == name {
+			return true
+		}
+	}
+	return false
+}
+
+func appendLabels(labels []Label, additions ...Label) []Label {
+	for _, addition := range additions {
+		if !containsLabel(labels, addition) {
+			labels = append(labels, addition)
+		}
+	}
+	return labels
+}
+
+func containsLabel(labels []Label, label Label) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
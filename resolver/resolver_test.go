@@ -0,0 +1,106 @@
+package resolver
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testConfig = &Config{
+	Labels: []Label{{Name: "synced"}},
+	Lists:  []string{"Inbox"},
+	SyncActions: map[string]ActionConfig{
+		string(OPEN): {
+			IssueTypes: map[string]IssueTypeConfig{
+				string(PULL_REQUEST): {
+					Target: Target{Labels: []Label{{Name: "pr"}}, Lists: []string{"Reviews"}},
+					UserRelationship: UserRelationshipConfig{
+						Author: Target{Lists: []string{"Mine"}, Labels: []Label{{Color: "green"}}},
+					},
+				},
+				string(ISSUE): {
+					UserRelationship: UserRelationshipConfig{
+						Assignee:  Target{Lists: []string{"Doing"}, Labels: []Label{{Name: "assigned"}}},
+						Mentioned: Target{Lists: []string{"Mentions"}, Labels: []Label{{Name: "synced"}}},
+					},
+				},
+			},
+		},
+		string(UPDATE): {
+			Target: Target{Labels: []Label{{Name: "updated"}}},
+		},
+		string(CLOSE): {
+			Target:  Target{Lists: []string{"Done"}},
+			Archive: true,
+		},
+	},
+}
+
+func TestResolve(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		itemType  ItemType
+		action    Action
+		relations []Relation
+		expected  Result
+	}{
+		{
+			name:     "base",
+			itemType: ISSUE,
+			action:   OPEN,
+			expected: Result{Labels: []Label{{Name: "synced"}}, Lists: []string{"Inbox"}},
+		},
+		{
+			name:     "issue type overrides base lists",
+			itemType: PULL_REQUEST,
+			action:   OPEN,
+			expected: Result{Labels: []Label{{Name: "synced"}, {Name: "pr"}}, Lists: []string{"Reviews"}},
+		},
+		{
+			name:      "relation overrides issue type lists",
+			itemType:  PULL_REQUEST,
+			action:    OPEN,
+			relations: []Relation{AUTHOR},
+			expected: Result{
+				Labels: []Label{{Name: "synced"}, {Name: "pr"}, {Color: "green"}},
+				Lists:  []string{"Mine"},
+			},
+		},
+		{
+			name:      "relation lists are combined, labels deduplicated",
+			itemType:  ISSUE,
+			action:    OPEN,
+			relations: []Relation{ASSIGNEE, MENTIONED},
+			expected: Result{
+				Labels: []Label{{Name: "synced"}, {Name: "assigned"}},
+				Lists:  []string{"Doing", "Mentions"},
+			},
+		},
+		{
+			name:      "relation without a target keeps lower lists",
+			itemType:  ISSUE,
+			action:    OPEN,
+			relations: []Relation{AUTHOR},
+			expected:  Result{Labels: []Label{{Name: "synced"}}, Lists: []string{"Inbox"}},
+		},
+		{
+			name:      "base lists don't apply to update",
+			itemType:  ISSUE,
+			action:    UPDATE,
+			relations: []Relation{ASSIGNEE},
+			expected:  Result{Labels: []Label{{Name: "synced"}, {Name: "updated"}}},
+		},
+		{
+			name:     "action lists override base lists",
+			itemType: ISSUE,
+			action:   CLOSE,
+			expected: Result{Archive: true, Labels: []Label{{Name: "synced"}}, Lists: []string{"Done"}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := New(testConfig).Resolve(test.itemType, test.action, test.relations)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, result)
+			}
+		})
+	}
+}
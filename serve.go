@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const shutdownTimeout = 30 * time.Second
+
+// serve runs an HTTP server on addr until SIGINT or SIGTERM is received.
+// listening, if set, is called once the server accepts connections.
+func serve(addr string, handler http.Handler, listening func() error) error {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to serve on %s", addr)
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		fmt.Printf("Listening on %s\n", addr)
+		errs <- server.Serve(listener)
+	}()
+	if listening != nil {
+		go func() {
+			if err := listening(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		server.Close()
+		return errors.Wrapf(err, "Unable to serve on %s", addr)
+	case sig := <-signals:
+		fmt.Printf("Received %s, shutting down\n", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
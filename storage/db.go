@@ -3,7 +3,9 @@ package storage
 import (
 	"database/sql"
 	"fmt"
-	"reflect"
+	"io"
+	"io/ioutil"
+	"os"
 
 	_ "github.com/mattn/go-sqlite3"
 
@@ -11,13 +13,40 @@ import (
 	"github.com/pkg/errors"
 )
 
+const defaultPath = "github-to-trello.db"
+
+type Config struct {
+	// Path is the SQLite database file holding sync state
+	Path string
+	// Reset drops all stored state before the schema is migrated
+	Reset bool
+	// Scratch works on a throwaway copy of the database so nothing is persisted
+	Scratch bool
+}
+
 type DB struct {
 	dbMap *gorp.DbMap
+
+	scratchPath string // removed on close
 }
 
-func DBInit() (*DB, error) {
-	fmt.Println("Initializing data store connection")
-	db, err := sql.Open("sqlite3", "/tmp/post_db.bin")
+func DBInit(config Config) (*DB, error) {
+	path := config.Path
+	if len(path) == 0 {
+		path = defaultPath
+	}
+
+	scratchPath := ""
+	if config.Scratch {
+		var err error
+		if scratchPath, err = scratchCopy(path); err != nil {
+			return nil, errors.Wrap(err, "Failed to copy data store")
+		}
+		path = scratchPath
+	}
+
+	fmt.Printf("Initializing data store connection to %s\n", path)
+	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to initialize data store connection")
 	}
@@ -27,22 +56,60 @@ func DBInit() (*DB, error) {
 		Dialect: gorp.SqliteDialect{},
 	}
 
-	_ = dbMap.TruncateTables()
+	// schema is owned by the migrations, tables are only mapped here
+	dbMap.AddTableWithName(Card{}, "cardInstances").SetKeys(true, "Id")
+	dbMap.AddTableWithName(Comment{}, "comments").SetKeys(true, "Id")
+	dbMap.AddTableWithName(Issue{}, "issues").SetKeys(true, "Id")
+	dbMap.AddTableWithName(TrelloComment{}, "trelloComments").SetKeys(true, "Id")
+	dbMap.AddTableWithName(Watermark{}, "watermarks").SetKeys(false, "Query")
 
-	dbMap.AddTableWithName(Card{}, "cardInstances").SetKeys(true, "Id").AddIndex("TrelloCardIndex", "Hash", []string{"TrelloCardId"}).SetUnique(true)
-	cardTable, _ := dbMap.TableFor(reflect.TypeOf(Card{}), false)
-	cardTable.AddIndex("IssueCardIndex", "BTree", []string{"IssueId", "ListId"})
-	dbMap.AddTableWithName(Comment{}, "comments").SetKeys(true, "Id").AddIndex("IssueIdIndex", "Hash", []string{"IssueId"})
-	dbMap.AddTableWithName(Issue{}, "issues").SetKeys(true, "Id").AddIndex("IssueIdIndex", "Hash", []string{"IssueId"}).SetUnique(true)
+	d := &DB{
+		dbMap:       dbMap,
+		scratchPath: scratchPath,
+	}
 
-	if err = dbMap.CreateTablesIfNotExists(); err != nil {
-		return nil, errors.Wrap(err, "Failed to create data store tables")
+	if config.Reset {
+		if err = d.reset(); err != nil {
+			return nil, errors.Wrap(err, "Failed to reset data store")
+		}
+	}
+	if err = d.migrate(); err != nil {
+		return nil, errors.Wrap(err, "Failed to migrate data store")
 	}
 
 	fmt.Println("Data store connection successfully initialized")
-	return &DB{
-		dbMap: dbMap,
-	}, nil
+	return d, nil
+}
+
+// scratchCopy copies the database at path, if any, to a temporary file
+func scratchCopy(path string) (string, error) {
+	scratch, err := ioutil.TempFile("", "github-to-trello-state")
+	if err != nil {
+		return "", err
+	}
+	defer scratch.Close()
+
+	original, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return scratch.Name(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer original.Close()
+
+	if _, err = io.Copy(scratch, original); err != nil {
+		return "", err
+	}
+	return scratch.Name(), nil
+}
+
+func (d *DB) Close() error {
+	err := d.dbMap.Db.Close()
+	if len(d.scratchPath) > 0 {
+		os.Remove(d.scratchPath)
+	}
+	return err
 }
 
 func (d *DB) GetOne(holder interface{}, query string, args ...interface{}) error {
@@ -67,3 +134,35 @@ func (d *DB) Update(holders ...interface{}) (int64, error) {
 	}
 	return count, nil
 }
+
+func (d *DB) Select(holder interface{}, query string, args ...interface{}) error {
+	if _, err := d.dbMap.Select(holder, query, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *DB) SelectInt(query string, args ...interface{}) (int64, error) {
+	return d.dbMap.SelectInt(query, args...)
+}
+
+// Transaction runs fn in a transaction, rolling back if it fails
+func (d *DB) Transaction(fn func(tx *gorp.Transaction) error) error {
+	tx, err := d.dbMap.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) Exec(query string, args ...interface{}) (int64, error) {
+	result, err := d.dbMap.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
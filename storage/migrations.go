@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// migration is a versioned set of schema changes. Migrations are applied in
+// order and never edited once released - add a new one instead.
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Create issues, comments and card instances",
+		statements: []string{
+			`create table if not exists issues (
+				id integer primary key autoincrement,
+				body text not null default '',
+				issue_id text not null,
+				number integer not null default 0,
+				repository text not null default '',
+				title text not null default '',
+				url text not null default '',
+				user_relationship text not null default ''
+			)`,
+			`create unique index if not exists IssueIdIndex on issues (issue_id)`,
+			`create table if not exists comments (
+				id integer primary key autoincrement,
+				issue_id text not null,
+				body text not null default ''
+			)`,
+			`create index if not exists CommentIssueIdIndex on comments (issue_id)`,
+			`create table if not exists cardInstances (
+				id integer primary key autoincrement,
+				issue_id integer not null,
+				title text not null default '',
+				text text not null default '',
+				trello_card_id text not null,
+				list_id text not null default '',
+				label_ids text not null default ''
+			)`,
+			`create unique index if not exists TrelloCardIndex on cardInstances (trello_card_id)`,
+			`create index if not exists IssueCardIndex on cardInstances (issue_id, list_id)`,
+		},
+	},
+	{
+		version:     2,
+		description: "Track issue type and closure",
+		statements: []string{
+			`alter table issues add column type text not null default 'issue'`,
+			`alter table issues add column closed integer not null default 0`,
+			`alter table issues add column close_reason text not null default ''`,
+		},
+	},
+	{
+		version:     3,
+		description: "Track trello comments mirrored to GitHub",
+		statements: []string{
+			`create table if not exists trelloComments (
+				id integer primary key autoincrement,
+				trello_action_id text not null,
+				issue_id text not null,
+				github_comment_id text not null default ''
+			)`,
+			`create unique index if not exists TrelloActionIndex on trelloComments (trello_action_id)`,
+		},
+	},
+	{
+		version:     4,
+		description: "Key card instances by GitHub node ID",
+		statements: []string{
+			`alter table cardInstances add column github_id text not null default ''`,
+			`update cardInstances set github_id = coalesce(
+				(select issue_id from issues where issues.id = cardInstances.issue_id),
+				''
+			)`,
+			`create index if not exists GitHubCardIndex on cardInstances (github_id, list_id)`,
+		},
+	},
+	{
+		version:     5,
+		description: "Map comments to trello comment actions",
+		statements: []string{
+			`alter table comments add column card_id integer not null default 0`,
+			`alter table comments add column trello_action_id text not null default ''`,
+			`create index if not exists CardCommentIndex on comments (card_id)`,
+		},
+	},
+	{
+		version:     6,
+		description: "Identify comments by GitHub node ID",
+		statements: []string{
+			`alter table comments add column github_id text not null default ''`,
+			`alter table comments add column updated_at text not null default ''`,
+		},
+	},
+	{
+		version:     7,
+		description: "Track incremental search watermarks",
+		statements: []string{
+			`create table if not exists watermarks (
+				query text primary key,
+				synced_at text not null default '',
+				swept_at text not null default ''
+			)`,
+		},
+	},
+	{
+		version:     8,
+		description: "Store rendered card titles",
+		statements: []string{
+			`alter table issues add column card_title text not null default ''`,
+			`update issues set card_title = title`,
+		},
+	},
+	{
+		version:     9,
+		description: "Track GitHub labels",
+		statements: []string{
+			`alter table issues add column labels text not null default ''`,
+		},
+	},
+	{
+		version:     10,
+		description: "Track GitHub assignees and reviewers",
+		statements: []string{
+			`alter table issues add column members text not null default ''`,
+		},
+	},
+	{
+		version:     11,
+		description: "Track milestones and due dates",
+		statements: []string{
+			`alter table issues add column milestone text not null default ''`,
+			`alter table issues add column due text not null default ''`,
+			`alter table cardInstances add column due text not null default ''`,
+		},
+	},
+}
+
+var tableNames = []string{
+	"cardInstances",
+	"comments",
+	"issues",
+	"trelloComments",
+	"watermarks",
+	"schema_migrations",
+}
+
+func (d *DB) migrate() error {
+	if _, err := d.dbMap.Exec(
+		`create table if not exists schema_migrations (
+			version integer primary key,
+			description text not null,
+			applied_at datetime not null
+		)`,
+	); err != nil {
+		return errors.Wrap(err, "Failed to create schema migrations table")
+	}
+
+	current, err := d.dbMap.SelectInt("select coalesce(max(version), 0) from schema_migrations")
+	if err != nil {
+		return errors.Wrap(err, "Failed to read schema version")
+	}
+
+	for _, m := range migrations {
+		if int64(m.version) <= current {
+			continue
+		}
+		fmt.Printf("Applying data store migration %d: %s\n", m.version, m.description)
+		if err := d.applyMigration(m); err != nil {
+			return errors.Wrapf(err, "Failed to apply data store migration %d", m.version)
+		}
+	}
+	return nil
+}
+
+func (d *DB) applyMigration(m migration) error {
+	tx, err := d.dbMap.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range m.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		"insert into schema_migrations (version, description, applied_at) values (?, ?, ?)",
+		m.version,
+		m.description,
+		time.Now().UTC(),
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reset drops all state so the schema is rebuilt from the first migration
+func (d *DB) reset() error {
+	fmt.Println("Resetting data store")
+	for _, tableName := range tableNames {
+		if _, err := d.dbMap.Exec(fmt.Sprintf("drop table if exists %s", tableName)); err != nil {
+			return errors.Wrapf(err, "Failed to drop table %s", tableName)
+		}
+	}
+	return nil
+}
@@ -8,31 +8,72 @@ const (
 	CLOSE             = "CLOSE"
 )
 
+type IssueType string
+
+const (
+	ISSUE        IssueType = "issue"
+	PULL_REQUEST           = "pull_request"
+)
+
 type Issue struct {
-	Id               int64  `db:"primarykey, autoincrement"`
-	Body             string `db:"body"`
-	IssueId          string `db:"issue_id"`
-	Number           int64  `db:"number"`
-	Repository       string `db:"repository"`
-	Title            string `db:"title"`
-	URL              string `db:"url"`
-	UserRelationship string `db:"user_relationship"`
+	Id               int64     `db:"id"`
+	Body             string    `db:"body"`
+	CardTitle        string    `db:"card_title"`
+	Closed           bool      `db:"closed"`
+	CloseReason      string    `db:"close_reason"`
+	Due              string    `db:"due"` // RFC 3339, empty without a due date
+	IssueId          string    `db:"issue_id"`
+	Labels           string    `db:"labels"`  // GitHub labels as JSON
+	Members          string    `db:"members"` // GitHub assignees and requested reviewers as JSON
+	Milestone        string    `db:"milestone"`
+	Number           int64     `db:"number"`
+	Repository       string    `db:"repository"`
+	Title            string    `db:"title"`
+	Type             IssueType `db:"type"`
+	URL              string    `db:"url"`
+	UserRelationship string    `db:"user_relationship"`
 
 	Comments []*Comment `db:"-"`
 }
 
+// Comment is a GitHub comment of an issue. Comments with a CardId map the
+// trello comment action the comment was synced to on that card.
 type Comment struct {
-	Id      int64  `db:"primarykey, autoincrement"`
+	Id      int64  `db:"id"`
 	IssueId string `db:"issue_id"`
-	Body    string `db:"body"`
+	CardId  int64  `db:"card_id"`
+	// GitHubId is the comment's node ID, UpdatedAt its last edit in RFC 3339
+	GitHubId       string `db:"github_id"`
+	UpdatedAt      string `db:"updated_at"`
+	TrelloActionId string `db:"trello_action_id"`
+	Body           string `db:"body"`
 }
 
 type Card struct {
-	Id           int64  `db:"primarykey, autoincrement"`
+	Id           int64  `db:"id"`
 	IssueId      int64  `db:"issue_id"`
+	GitHubId     string `db:"github_id"`
 	Title        string `db:"title"`
 	Text         string `db:"text"`
 	TrelloCardId string `db:"trello_card_id"`
 	ListId       string `db:"list_id"`
 	LabelIds     string `db:"label_ids"`
+	Due          string `db:"due"` // RFC 3339, empty without a due date
+}
+
+// TrelloComment is a comment made on a trello card that was mirrored to GitHub
+type TrelloComment struct {
+	Id              int64  `db:"id"`
+	TrelloActionId  string `db:"trello_action_id"`
+	IssueId         string `db:"issue_id"`
+	GitHubCommentId string `db:"github_comment_id"`
+}
+
+// Watermark records the runs of a GitHub search. SyncedAt is the start of the
+// last successful run and SweptAt of the last one that was not incremental,
+// both in RFC 3339.
+type Watermark struct {
+	Query    string `db:"query"`
+	SyncedAt string `db:"synced_at"`
+	SweptAt  string `db:"swept_at"`
 }
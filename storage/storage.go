@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/go-gorp/gorp"
 	"github.com/pkg/errors"
 )
 
@@ -12,8 +13,8 @@ type Storage struct {
 	db *DB
 }
 
-func Init() *Storage {
-	db, err := DBInit()
+func Init(config Config) *Storage {
+	db, err := DBInit(config)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %s", err)
 	}
@@ -40,6 +41,149 @@ func (s *Storage) FindIssue(issueId string) (*Issue, error) {
 	return issue, nil
 }
 
+func (s *Storage) GetIssue(id int64) (*Issue, error) {
+	issue := &Issue{}
+	if err := s.db.GetOne(
+		issue,
+		"select * from issues where id=?",
+		id,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Error getting issue")
+	}
+	return issue, nil
+}
+
+// FindOpenIssues returns all tracked issues of the given type that have not been closed
+func (s *Storage) FindOpenIssues(issueType IssueType) ([]*Issue, error) {
+	issues := []*Issue{}
+	if err := s.db.Select(
+		&issues,
+		"select * from issues where type=? and closed=0",
+		string(issueType),
+	); err != nil {
+		return nil, errors.Wrap(err, "Error finding open issues")
+	}
+	return issues, nil
+}
+
+func (s *Storage) FindComments(issueId string) ([]*Comment, error) {
+	comments := []*Comment{}
+	if err := s.db.Select(
+		&comments,
+		"select * from comments where issue_id=? and card_id=0 order by id",
+		issueId,
+	); err != nil {
+		return nil, errors.Wrap(err, "Error finding comments")
+	}
+	return comments, nil
+}
+
+func (s *Storage) FindCards(issueId int64) ([]*Card, error) {
+	cards := []*Card{}
+	if err := s.db.Select(
+		&cards,
+		"select * from cardInstances where issue_id=? order by id",
+		issueId,
+	); err != nil {
+		return nil, errors.Wrap(err, "Error finding cards")
+	}
+	return cards, nil
+}
+
+func (s *Storage) FindAllCards() ([]*Card, error) {
+	cards := []*Card{}
+	if err := s.db.Select(
+		&cards,
+		"select * from cardInstances order by id",
+	); err != nil {
+		return nil, errors.Wrap(err, "Error finding cards")
+	}
+	return cards, nil
+}
+
+// FindListCard returns the card instance of a GitHub node on the given list, nil if there is none
+func (s *Storage) FindListCard(gitHubId, listId string) (*Card, error) {
+	card := &Card{}
+	if err := s.db.GetOne(
+		card,
+		"select * from cardInstances where github_id=? and list_id=? order by id limit 1",
+		gitHubId,
+		listId,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Error finding card")
+	}
+	return card, nil
+}
+
+// FindCardByTrelloId returns the tracked card instance with the given trello card ID, nil if untracked
+func (s *Storage) FindCardByTrelloId(trelloCardId string) (*Card, error) {
+	card := &Card{}
+	if err := s.db.GetOne(
+		card,
+		"select * from cardInstances where trello_card_id=?",
+		trelloCardId,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Error finding card")
+	}
+	return card, nil
+}
+
+// IsMirroredComment reports whether the trello comment action was already mirrored to GitHub
+func (s *Storage) IsMirroredComment(trelloActionId string) (bool, error) {
+	count, err := s.db.SelectInt(
+		"select count(*) from trelloComments where trello_action_id=?",
+		trelloActionId,
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "Error finding mirrored comment")
+	}
+	return count > 0, nil
+}
+
+func (s *Storage) SaveMirroredComment(comment *TrelloComment) error {
+	if err := s.db.Insert(comment); err != nil {
+		return errors.Wrap(err, "Error saving mirrored comment")
+	}
+	return nil
+}
+
+// FindWatermark returns the watermark of the named search, nil if it never ran
+func (s *Storage) FindWatermark(query string) (*Watermark, error) {
+	watermark := &Watermark{}
+	if err := s.db.GetOne(
+		watermark,
+		"select * from watermarks where query=?",
+		query,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Error finding watermark")
+	}
+	return watermark, nil
+}
+
+func (s *Storage) SaveWatermark(watermark *Watermark) error {
+	if _, err := s.db.Exec(
+		"insert or replace into watermarks (query, synced_at, swept_at) values (?, ?, ?)",
+		watermark.Query,
+		watermark.SyncedAt,
+		watermark.SweptAt,
+	); err != nil {
+		return errors.Wrap(err, "Error saving watermark")
+	}
+	return nil
+}
+
 func (s *Storage) SaveNewIssue(issue *Issue) error {
 	fmt.Println("\tSaving new issue")
 	if err := s.db.Insert(issue); err != nil {
@@ -52,6 +196,32 @@ func (s *Storage) SaveNewIssue(issue *Issue) error {
 	return nil
 }
 
+// UpdateIssue persists the issue row and replaces its stored comments
+func (s *Storage) UpdateIssue(issue *Issue) error {
+	fmt.Println("\tSaving updated issue")
+	if _, err := s.db.Update(issue); err != nil {
+		return errors.Wrap(err, "Error updating issue")
+	}
+	if _, err := s.db.Exec("delete from comments where issue_id=? and card_id=0", issue.IssueId); err != nil {
+		return errors.Wrap(err, "Error updating issue comments")
+	}
+	if err := s.saveComments(issue.Comments); err != nil {
+		return errors.Wrap(err, "Error updating issue comments")
+	}
+	return nil
+}
+
+// CloseIssue records the issue as closed so it is not processed again
+func (s *Storage) CloseIssue(issue *Issue, reason string) error {
+	fmt.Printf("\tSaving closed issue (%s)\n", reason)
+	issue.Closed = true
+	issue.CloseReason = reason
+	if _, err := s.db.Update(issue); err != nil {
+		return errors.Wrap(err, "Error closing issue")
+	}
+	return nil
+}
+
 func (s *Storage) SaveNewCard(card *Card) error {
 	if err := s.db.Insert(card); err != nil {
 		return errors.Wrap(err, "Error saving new card")
@@ -76,6 +246,82 @@ func (s *Storage) UpdateCard(card *Card) (int64, error) {
 	return count, nil
 }
 
+// FindCardComments returns the trello comment actions mapped on a card
+func (s *Storage) FindCardComments(cardId int64) ([]*Comment, error) {
+	comments := []*Comment{}
+	if err := s.db.Select(
+		&comments,
+		"select * from comments where card_id=? order by id",
+		cardId,
+	); err != nil {
+		return nil, errors.Wrap(err, "Error finding card comments")
+	}
+	return comments, nil
+}
+
+// ReplaceCardComments swaps the trello comment actions mapped on a card for comments
+func (s *Storage) ReplaceCardComments(cardId int64, comments []*Comment) error {
+	if err := s.db.Transaction(func(tx *gorp.Transaction) error {
+		if _, err := tx.Exec("delete from comments where card_id=?", cardId); err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			comment.CardId = cardId
+			if err := tx.Insert(comment); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "Error replacing card comments")
+	}
+	return nil
+}
+
+// ReplaceCards makes cards the stored card instances. Cards carrying the ID of
+// a stored instance update it and keep its comment mappings, the instances
+// left out are deleted along with theirs.
+func (s *Storage) ReplaceCards(cards []*Card) error {
+	if err := s.db.Transaction(func(tx *gorp.Transaction) error {
+		kept := map[int64]bool{}
+		for _, card := range cards {
+			kept[card.Id] = true
+		}
+
+		stored := []*Card{}
+		if _, err := tx.Select(&stored, "select * from cardInstances"); err != nil {
+			return err
+		}
+		for _, card := range stored {
+			if kept[card.Id] {
+				continue
+			}
+			if _, err := tx.Exec("delete from comments where card_id=?", card.Id); err != nil {
+				return err
+			}
+			if _, err := tx.Delete(card); err != nil {
+				return err
+			}
+		}
+
+		for _, card := range cards {
+			if card.Id != 0 {
+				if _, err := tx.Update(card); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Insert(card); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "Error replacing cards")
+	}
+	return nil
+}
+
 func (s *Storage) Close() {
-	s.db.dbMap.Db.Close()
+	s.db.Close()
 }
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestStorage(t *testing.T) (*Storage, func()) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Init(Config{Path: filepath.Join(dir, "test.db")})
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReplaceCards(t *testing.T) {
+	s, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	kept := &Card{GitHubId: "kept", TrelloCardId: "trello-kept", ListId: "todo"}
+	gone := &Card{GitHubId: "gone", TrelloCardId: "trello-gone"}
+	for _, card := range []*Card{kept, gone} {
+		if err := s.SaveNewCard(card); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReplaceCardComments(card.Id, []*Comment{{GitHubId: card.GitHubId, TrelloActionId: "action"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved := &Card{Id: kept.Id, GitHubId: "kept", TrelloCardId: "trello-kept", ListId: "done"}
+	added := &Card{GitHubId: "added", TrelloCardId: "trello-added"}
+	if err := s.ReplaceCards([]*Card{moved, added}); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, err := s.FindAllCards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 2 || cards[0].Id != kept.Id || cards[0].ListId != "done" || cards[1].TrelloCardId != "trello-added" {
+		t.Errorf("expected the kept card to be updated and the added one inserted, got %+v %+v", cards[0], cards[1])
+	}
+
+	comments, err := s.FindCardComments(kept.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 {
+		t.Errorf("expected the kept card to keep its comment mapping, got %d", len(comments))
+	}
+	if comments, err = s.FindCardComments(gone.Id); err != nil || len(comments) != 0 {
+		t.Errorf("expected the mappings of the dropped card to be deleted, got %d (%v)", len(comments), err)
+	}
+}
+
+func TestMirroredCommentsAreUnique(t *testing.T) {
+	s, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	comment := &TrelloComment{TrelloActionId: "action", IssueId: "issue"}
+	if err := s.SaveMirroredComment(comment); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMirroredComment(&TrelloComment{TrelloActionId: "action", IssueId: "issue"}); err == nil {
+		t.Error("expected a trello comment to be mirrored only once")
+	}
+}
@@ -0,0 +1,487 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
+	"github.com/luccacabra/github-to-trello/storage"
+	"github.com/luccacabra/github-to-trello/syncer"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+
+	"github.com/pkg/errors"
+)
+
+// cardSyncer holds the card bookkeeping shared by the issue and pull request syncers
+type cardSyncer struct {
+	trello *trelloWrapper.Client
+	github *github.Client
+
+	storage  *storage.Storage
+	resolver *resolver.Resolver
+	renderer *syncer.Renderer
+}
+
+// actions resolves the open, update and close lists and labels for an item
+func (c *cardSyncer) actions(itemType resolver.ItemType, relations []resolver.Relation) trelloWrapper.Actions {
+	actions := trelloWrapper.Actions{}
+
+	open := c.resolver.Resolve(itemType, resolver.OPEN, relations)
+	actions.Create.Lists = open.Lists
+	actions.Create.Labels = trelloLabels(open.Labels)
+
+	update := c.resolver.Resolve(itemType, resolver.UPDATE, relations)
+	actions.Update.Lists = update.Lists
+	actions.Update.Labels = trelloLabels(update.Labels)
+
+	close := c.resolver.Resolve(itemType, resolver.CLOSE, relations)
+	actions.Close.Lists = close.Lists
+	actions.Close.Labels = trelloLabels(close.Labels)
+	actions.Close.Archive = close.Archive
+
+	return actions
+}
+
+func (c *cardSyncer) syncNew(issue *storage.Issue, actionConfig trelloWrapper.Actions) error {
+	if err := c.storage.SaveNewIssue(issue); err != nil {
+		return errors.Wrapf(err, "Error syncing new issue \"%s\"", issue.Title)
+	}
+
+	gitHubLabelIds, err := c.gitHubLabelIds(issue, true)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing new issue \"%s\"", issue.Title)
+	}
+	memberIds := c.gitHubMemberIds(issue.Members, true)
+
+	for _, listName := range actionConfig.Create.Lists {
+		fmt.Printf("\tSyncing new issue for list %s\n", listName)
+
+		card := c.convertIssueToCard(issue, actionConfig.Create.Labels, listName)
+		card.LabelIds = mergeLabelIds(card.LabelIds, gitHubLabelIds)
+
+		if err := c.createNewCard(card, issue.URL, memberIds, issue.Comments); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncExisting diffs a freshly fetched issue against its stored state and
+// pushes any changes to every card instance of the issue
+func (c *cardSyncer) syncExisting(stored, fresh *storage.Issue, actionConfig trelloWrapper.Actions) error {
+	storedComments, err := c.storage.FindComments(stored.IssueId)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing existing issue \"%s\"", fresh.Title)
+	}
+
+	contentChanged := stored.CardTitle != fresh.CardTitle || stored.Body != fresh.Body
+	commentsChanged := !commentsEqual(storedComments, fresh.Comments)
+	relationshipChanged := stored.UserRelationship != fresh.UserRelationship
+	labelsChanged := stored.Labels != fresh.Labels || stored.Milestone != fresh.Milestone
+	membersChanged := stored.Members != fresh.Members
+	dueChanged := stored.Due != fresh.Due
+	reopened := stored.Closed
+
+	cards, err := c.storage.FindCards(stored.Id)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing existing issue \"%s\"", fresh.Title)
+	}
+	// cards whose creation failed on an earlier run are created now
+	if err := c.createMissingCards(stored, fresh, cards, actionConfig); err != nil {
+		return errors.Wrapf(err, "Error syncing existing issue \"%s\"", fresh.Title)
+	}
+
+	if !contentChanged && !commentsChanged && !relationshipChanged && !labelsChanged && !membersChanged && !dueChanged && !reopened {
+		fmt.Printf("\tNo changes for issue \"%s\"\n", fresh.Title)
+		return nil
+	}
+
+	labels, err := c.gitHubLabelUpdate(stored, fresh)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing existing issue \"%s\"", fresh.Title)
+	}
+	members := c.gitHubMemberUpdate(stored.Members, fresh.Members)
+
+	for idx, storageCard := range cards {
+		fmt.Printf("\tSyncing existing issue for card %s\n", storageCard.TrelloCardId)
+		if err := c.updateCard(idx, storageCard, fresh, actionConfig, labels, members, contentChanged, commentsChanged, relationshipChanged, reopened); err != nil {
+			return errors.Wrapf(err, "Error syncing existing issue \"%s\"", fresh.Title)
+		}
+	}
+
+	fresh.Id = stored.Id
+	if err := c.storage.UpdateIssue(fresh); err != nil {
+		return errors.Wrapf(err, "Error syncing existing issue \"%s\"", fresh.Title)
+	}
+	return nil
+}
+
+// createMissingCards creates the cards of the create lists past the cards
+// already stored for the issue, which are created in list order. They are
+// created from the fresh issue and left out of the update that follows.
+func (c *cardSyncer) createMissingCards(stored, fresh *storage.Issue, cards []*storage.Card, actionConfig trelloWrapper.Actions) error {
+	if len(cards) >= len(actionConfig.Create.Lists) {
+		return nil
+	}
+
+	fresh.Id = stored.Id
+	gitHubLabelIds, err := c.gitHubLabelIds(fresh, true)
+	if err != nil {
+		return err
+	}
+	memberIds := c.gitHubMemberIds(fresh.Members, true)
+
+	for _, listName := range actionConfig.Create.Lists[len(cards):] {
+		fmt.Printf("\tSyncing missing card for list %s\n", listName)
+
+		card := c.convertIssueToCard(fresh, actionConfig.Create.Labels, listName)
+		card.LabelIds = mergeLabelIds(card.LabelIds, gitHubLabelIds)
+
+		if err := c.createNewCard(card, fresh.URL, memberIds, fresh.Comments); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cardSyncer) updateCard(
+	idx int,
+	storageCard *storage.Card,
+	issue *storage.Issue,
+	actionConfig trelloWrapper.Actions,
+	labels labelUpdate,
+	members memberUpdate,
+	contentChanged, commentsChanged, relationshipChanged, reopened bool,
+) error {
+	storageCard.Title = issue.CardTitle
+	storageCard.Text = issue.Body
+
+	args := map[string]string{}
+	if contentChanged {
+		args["name"] = storageCard.Title
+		args["desc"] = storageCard.Text
+	}
+	if storageCard.Due != issue.Due {
+		storageCard.Due = issue.Due
+		args["due"] = dueArg(issue.Due)
+	}
+	lists := actionConfig.Update.Lists
+	// reopened issues are restored from their archived or closed lists
+	if reopened {
+		args["closed"] = "false"
+		if len(lists) == 0 {
+			lists = actionConfig.Create.Lists
+		}
+	}
+	// cards are only moved when the issue moved, other changes leave them
+	// wherever they were triaged to on the board
+	if listName, ok := listForCard(lists, idx); ok && (relationshipChanged || reopened) {
+		storageCard.ListId = c.trello.GetListIdForName(listName)
+		args["idList"] = storageCard.ListId
+	}
+
+	card := c.trello.NewCard(storageCard)
+	if len(args) > 0 {
+		if err := card.Update(args); err != nil {
+			return errors.Wrapf(err, "Error updating card %s", storageCard.TrelloCardId)
+		}
+	}
+	// labels are added and removed one by one so labels added on the board stay
+	addLabels := append(append([]string{}, labels.add...), c.trello.GetLabelIds(actionConfig.Update.Labels)...)
+	if err := card.UpdateLabels(addLabels, subtractIds(labels.remove, addLabels)); err != nil {
+		return err
+	}
+	if err := card.UpdateMembers(members.add, members.remove); err != nil {
+		return err
+	}
+
+	if commentsChanged {
+		if err := c.syncComments(card, storageCard, issue.Comments); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.storage.UpdateCard(storageCard); err != nil {
+		return err
+	}
+	return nil
+}
+
+// syncClosed closes every tracked open issue of the given type that was not
+// seen during the current run
+func (c *cardSyncer) syncClosed(
+	issueType storage.IssueType,
+	seen map[string]bool,
+) error {
+	issues, err := c.storage.FindOpenIssues(issueType)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing closed %ss", issueType)
+	}
+
+	for _, issue := range issues {
+		if seen[issue.IssueId] {
+			continue
+		}
+		if err := c.closeIssue(issue); err != nil {
+			return errors.Wrapf(err, "Error syncing closed %s \"%s\"", issueType, issue.Title)
+		}
+	}
+	return nil
+}
+
+// closeTracked closes the issue with the given ID if it is tracked and still open
+func (c *cardSyncer) closeTracked(issueId string) error {
+	issue, err := c.storage.FindIssue(issueId)
+	if err != nil {
+		return err
+	}
+	if issue == nil || issue.Closed {
+		return nil
+	}
+	return c.closeIssue(issue)
+}
+
+func (c *cardSyncer) closeIssue(issue *storage.Issue) error {
+	status, err := c.github.Issues.Status(issue.IssueId)
+	if err != nil {
+		return err
+	}
+
+	actions := c.actions(resolver.ItemType(issue.Type), splitRelations(issue.UserRelationship))
+	reason := closeReason(issue, status)
+
+	fmt.Printf("Closing %s \"%s\" (%s)\n", issue.Type, issue.Title, reason)
+	return c.close(issue, reason, actions)
+}
+
+func (c *cardSyncer) close(issue *storage.Issue, reason string, actionConfig trelloWrapper.Actions) error {
+	cards, err := c.storage.FindCards(issue.Id)
+	if err != nil {
+		return err
+	}
+
+	for idx, storageCard := range cards {
+		fmt.Printf("\tClosing card %s\n", storageCard.TrelloCardId)
+		card := c.trello.NewCard(storageCard)
+		if err := card.UpdateLabels(c.trello.GetLabelIds(actionConfig.Close.Labels), nil); err != nil {
+			return errors.Wrapf(err, "Error closing card %s", storageCard.TrelloCardId)
+		}
+
+		args := map[string]string{}
+		if listName, ok := listForCard(actionConfig.Close.Lists, idx); ok {
+			storageCard.ListId = c.trello.GetListIdForName(listName)
+			args["idList"] = storageCard.ListId
+		}
+		if actionConfig.Close.Archive {
+			args["closed"] = "true"
+		}
+
+		if len(args) > 0 {
+			if err := card.Update(args); err != nil {
+				return errors.Wrapf(err, "Error closing card %s", storageCard.TrelloCardId)
+			}
+		}
+		if _, err := c.storage.UpdateCard(storageCard); err != nil {
+			return err
+		}
+	}
+
+	return c.storage.CloseIssue(issue, reason)
+}
+
+func closeReason(issue *storage.Issue, status *github.IssueStatus) string {
+	switch {
+	case !status.Found:
+		return "deleted"
+	case status.State == github.MERGED:
+		return "merged"
+	case status.State == github.CLOSED:
+		return "closed"
+	case !status.InOrg || status.Repository != issue.Repository:
+		return "transferred"
+	default:
+		return "no longer relevant"
+	}
+}
+
+func (c *cardSyncer) createNewCard(storageCard *storage.Card, issueURL string, memberIds []string, comments []*storage.Comment) error {
+	// Adopt a card rebuilt from the board that has no issue yet
+	existing, err := c.storage.FindListCard(storageCard.GitHubId, storageCard.ListId)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		storageCard.Id = existing.Id
+		storageCard.TrelloCardId = existing.TrelloCardId
+	}
+
+	// Create corresponding trello card
+	trelloCard, err := c.trello.CreateNewCard(storageCard, issueURL)
+	if err != nil {
+		return err
+	}
+	if err := trelloCard.UpdateMembers(memberIds, nil); err != nil {
+		return err
+	}
+
+	// Save new card
+	if existing != nil {
+		if _, err := c.storage.UpdateCard(storageCard); err != nil {
+			return errors.Wrapf(err, "Error creating new card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
+		}
+	} else if err := c.storage.SaveNewCard(storageCard); err != nil {
+		return errors.Wrapf(err, "Error creating new card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
+	}
+
+	// Sync Issue comments
+	return c.syncComments(trelloCard, storageCard, comments)
+}
+
+// syncComments syncs comments to the card and stores which comment actions they map to
+func (c *cardSyncer) syncComments(card *trelloWrapper.Card, storageCard *storage.Card, comments []*storage.Comment) error {
+	mapped, err := c.storage.FindCardComments(storageCard.Id)
+	if err != nil {
+		return err
+	}
+	mappings, err := card.SyncComments(mapped, comments)
+	if err != nil {
+		return err
+	}
+	return c.storage.ReplaceCardComments(storageCard.Id, mappings)
+}
+
+func (c *cardSyncer) convertIssueToCard(issue *storage.Issue, labels []trelloWrapper.Label, listName string) *storage.Card {
+	return &storage.Card{
+		IssueId:  issue.Id,
+		GitHubId: issue.IssueId,
+		Title:    issue.CardTitle,
+		Text:     issue.Body,
+
+		LabelIds: strings.Join(c.trello.GetLabelIds(labels), ","),
+		ListId:   c.trello.GetListIdForName(listName),
+		Due:      issue.Due,
+	}
+}
+
+// dueArg sets a card's due date, trello clears it for null
+func dueArg(due string) string {
+	if len(due) == 0 {
+		return "null"
+	}
+	return due
+}
+
+// convertCommentNodes skips comments that were mirrored from trello, they
+// already live on the card
+func (c *cardSyncer) convertCommentNodes(issueId string, commentNodes []github.CommentNode) []*storage.Comment {
+	comments := []*storage.Comment{}
+	for _, commentNode := range commentNodes {
+		if syncer.IsTrelloComment(string(commentNode.Node.Body)) {
+			continue
+		}
+		comments = append(comments, &storage.Comment{
+			IssueId:   issueId,
+			GitHubId:  string(commentNode.Node.ID),
+			UpdatedAt: commentNode.Node.UpdatedAt.Format(time.RFC3339),
+			Body:      c.renderer.Comment(syncer.CommentItem(commentNode)),
+		})
+	}
+	return comments
+}
+
+func commentsEqual(a, b []*storage.Comment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx].GitHubId != b[idx].GitHubId ||
+			a[idx].UpdatedAt != b[idx].UpdatedAt ||
+			a[idx].Body != b[idx].Body {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLabelIds adds labelIds to the comma separated set of current label IDs
+func mergeLabelIds(current string, labelIds []string) string {
+	merged := []string{}
+	seen := map[string]bool{}
+	for _, labelId := range append(strings.Split(current, ","), labelIds...) {
+		if len(labelId) == 0 || seen[labelId] {
+			continue
+		}
+		seen[labelId] = true
+		merged = append(merged, labelId)
+	}
+	return strings.Join(merged, ",")
+}
+
+// listForCard pairs configured lists with card instances in creation order,
+// any extra cards follow the last configured list
+func listForCard(lists []string, idx int) (string, bool) {
+	if len(lists) == 0 {
+		return "", false
+	}
+	if idx >= len(lists) {
+		idx = len(lists) - 1
+	}
+	return lists[idx], true
+}
+
+// trelloLabels converts configured labels, they are resolved on the board by
+// name, colour or both
+func trelloLabels(labels []resolver.Label) []trelloWrapper.Label {
+	converted := make([]trelloWrapper.Label, len(labels))
+	for idx, label := range labels {
+		converted[idx] = trelloWrapper.Label{Name: label.Name, Color: label.Color}
+	}
+	return converted
+}
+
+// mergeRelations adds the stored relations of an item to the ones found by
+// this run, ordered as in all. Runs that aren't a complete full sweep can't
+// tell a dropped relation from one that wasn't searched for, so relations
+// are only ever dropped by a complete full sweep.
+func (c *cardSyncer) mergeRelations(itemId string, found, all []resolver.Relation) ([]resolver.Relation, error) {
+	stored, err := c.storage.FindIssue(itemId)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.Closed {
+		return found, nil
+	}
+
+	has := map[resolver.Relation]bool{}
+	for _, relation := range append(splitRelations(stored.UserRelationship), found...) {
+		has[relation] = true
+	}
+	merged := []resolver.Relation{}
+	for _, relation := range all {
+		if has[relation] {
+			merged = append(merged, relation)
+		}
+	}
+	return merged, nil
+}
+
+func joinRelations(relations []resolver.Relation) string {
+	names := make([]string, len(relations))
+	for idx, relation := range relations {
+		names[idx] = string(relation)
+	}
+	return strings.Join(names, ",")
+}
+
+func splitRelations(joined string) []resolver.Relation {
+	relations := []resolver.Relation{}
+	for _, name := range strings.Split(joined, ",") {
+		if len(name) > 0 {
+			relations = append(relations, resolver.Relation(name))
+		}
+	}
+	return relations
+}
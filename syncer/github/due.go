@@ -0,0 +1,17 @@
+package github
+
+import (
+	"time"
+
+	"github.com/luccacabra/github-to-trello/github"
+)
+
+// dueDate resolves the due date of a GitHub item in RFC 3339, empty when it
+// has none
+func (c *cardSyncer) dueDate(labels github.LabelConnection, body string, milestone github.Milestone) string {
+	due := c.resolver.DueDate(gitHubLabels(labels), body, milestone.DueOn.Time)
+	if due.IsZero() {
+		return ""
+	}
+	return due.UTC().Format(time.RFC3339)
+}
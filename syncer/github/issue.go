@@ -2,9 +2,10 @@ package github
 
 import (
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
 	"github.com/luccacabra/github-to-trello/storage"
 	"github.com/luccacabra/github-to-trello/syncer"
 	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
@@ -13,223 +14,214 @@ import (
 )
 
 var _ syncer.Syncer = (*issueSyncer)(nil)
+var _ syncer.ItemSyncer = (*issueSyncer)(nil)
 
 type issueSyncer struct {
-	trello *trelloWrapper.Client
-	github *github.Client
+	cardSyncer
 
-	storage *storage.Storage
-
-	config map[syncer.UserRelationship]trelloWrapper.Actions
+	sweepInterval time.Duration
 }
 
+// NewIssueSyncer returns a syncer that searches incrementally, with a full
+// sweep every sweepInterval (a day when zero)
 func NewIssueSyncer(
 	githubClient *github.Client,
 	trello *trelloWrapper.Client,
 	storage *storage.Storage,
-	config syncer.IssueConfig,
+	resolver *resolver.Resolver,
+	renderer *syncer.Renderer,
+	sweepInterval time.Duration,
 ) (o *issueSyncer) {
-	actionConfig := map[syncer.UserRelationship]trelloWrapper.Actions{}
-	actionConfig[syncer.ASSIGNEE] = config.Relationship.Assignee.Actions
-	actionConfig[syncer.MENTION] = config.Relationship.Assignee.Actions
-
 	return &issueSyncer{
-		github:  githubClient,
-		trello:  trello,
-		storage: storage,
-		config:  actionConfig,
+		cardSyncer: cardSyncer{
+			trello:   trello,
+			github:   githubClient,
+			storage:  storage,
+			resolver: resolver,
+			renderer: renderer,
+		},
+		sweepInterval: sweepInterval,
 	}
 }
 
 func (i *issueSyncer) Sync() error {
-	if err := i.syncAssigned(); err != nil {
-		return errors.Wrap(err, "Error syncing open assigned issues")
+	issues := []github.IssueNode{}
+	relations := map[string][]resolver.Relation{}
+
+	searches := []struct {
+		relation resolver.Relation
+		search   func(since time.Time) ([]github.IssueNode, error)
+	}{
+		{resolver.ASSIGNEE, i.github.Issues.Assigned},
+		{resolver.MENTIONED, i.github.Issues.Mentioned},
 	}
-	if err := i.syncMentioned(); err != nil {
-		return errors.Wrap(err, "Error syncing open mentioned issues")
+	queries := make([]string, len(searches))
+	for idx, s := range searches {
+		queries[idx] = issueQuery(s.relation)
 	}
-	//if err := i.syncClosed(); err != nil {
-	//	return errors.Wrap(err, "Error syncing closed assigned & mentioned issues")
-	//}
-	return nil
-}
-
-func (i *issueSyncer) sync(issueNodes []github.IssueNode, relationship syncer.UserRelationship) error {
-	for _, issueNode := range issueNodes {
-		fmt.Printf("Syncing issue \"%s\"\n", issueNode.Issue.Title)
-
-		// graphql API returns empty nodes sometimes
-		if len(issueNode.Issue.Title) == 0 {
+	sweep, err := i.startSweep(queries, i.sweepInterval)
+	if err != nil {
+		return errors.Wrap(err, "Error syncing issues")
+	}
+
+	// a deferred search leaves the run incomplete, its items may still be open
+	complete := true
+	completed := []string{}
+	for _, s := range searches {
+		query := issueQuery(s.relation)
+		since := sweep.since(query)
+		issueNodes, err := s.search(since)
+		if github.IsDeferred(err) {
+			fmt.Printf("[WARNING] Skipping %s issues: %s\n", s.relation, errors.Cause(err))
+			complete = false
 			continue
 		}
-
-		issue, err := i.storage.FindIssue(string(issueNode.Issue.ID))
 		if err != nil {
-			return errors.Wrapf(err, "Error syncing issue %s", issueNode.Issue.Title)
+			return errors.Wrapf(err, "Error syncing open %s issues", s.relation)
 		}
-		// New issue
-		if issue == nil {
-			if err = i.syncNew(issueNode, i.config[relationship]); err != nil {
-				fmt.Println("here 3")
-				return errors.Wrapf(err, "Error syncing issue %s", issueNode.Issue.Title)
+		completed = append(completed, query)
+		if since.IsZero() {
+			fmt.Printf("Found %d %s issues\n", len(issueNodes), s.relation)
+		} else {
+			fmt.Printf("Found %d %s issues updated since %s\n", len(issueNodes), s.relation, since.Format(time.RFC3339))
+		}
+
+		for _, issueNode := range issueNodes {
+			// graphql API returns empty nodes sometimes
+			if len(issueNode.Issue.Title) == 0 {
+				continue
+			}
+			issueId := string(issueNode.Issue.ID)
+			if _, ok := relations[issueId]; !ok {
+				issues = append(issues, issueNode)
 			}
+			relations[issueId] = append(relations[issueId], s.relation)
 		}
-		// Update Existing Issue
-
-		//card := i.convertIssueToCard(issue)
-		//
-		//fmt.Printf("Syncing issue \"%s\"\n", issue.Issue.Title)
-		//_, err := i.trello.CreateOrUpdateCardInstancesByActions(card, actionConfig)
-		//if err != nil {
-		//	return errors.Wrapf(err, "Error syncing issue \"%s\"", issue.Issue.Title)
-		//}
-		//
-		//err = i.syncComments(car d, issue, actionConfig)
-		//if err != nil {
-		//	return errors.Wrapf(err, "Error syncing comment for issue \"%s\"", issue.Issue.Title)
-		//}
 	}
-	return nil
-}
 
-func (i *issueSyncer) syncAssigned() error {
-	issues, err := i.github.Issues.Assigned()
-	if err != nil {
-		return err
+	searched := make([]resolver.Relation, len(searches))
+	for idx, s := range searches {
+		searched[idx] = s.relation
 	}
-	fmt.Printf("Syncing %d assigned issues", len(issues))
-	if err = i.sync(issues, syncer.ASSIGNEE); err != nil {
-		return err
+	for _, issueNode := range issues {
+		issueId := string(issueNode.Issue.ID)
+		// incremental searches include issues closed since the watermark
+		if github.IssueState(issueNode.Issue.State) != github.OPEN {
+			if err := i.closeTracked(issueId); err != nil {
+				return errors.Wrapf(err, "Error syncing closed issue %s", issueNode.Issue.Title)
+			}
+			continue
+		}
+		issueRelations := relations[issueId]
+		if !sweep.full || !complete {
+			if issueRelations, err = i.mergeRelations(issueId, issueRelations, searched); err != nil {
+				return errors.Wrapf(err, "Error syncing issue %s", issueNode.Issue.Title)
+			}
+		}
+		if err := i.sync(issueNode, issueRelations); err != nil {
+			return errors.Wrapf(err, "Error syncing issue %s", issueNode.Issue.Title)
+		}
 	}
-	return nil
-}
 
-func (i *issueSyncer) syncMentioned() error {
-	issues, err := i.github.Issues.Mentioned()
-	if err != nil {
-		return errors.Wrap(err, "unable to sync open mentioned issues")
+	if sweep.full && complete {
+		seen := map[string]bool{}
+		for issueId := range relations {
+			seen[issueId] = true
+		}
+		if err := i.syncClosed(storage.ISSUE, seen); err != nil {
+			return errors.Wrap(err, "Error syncing closed assigned & mentioned issues")
+		}
+	} else if !complete {
+		fmt.Printf("[WARNING] Not closing issues, some searches were deferred\n")
 	}
-	if err = i.sync(issues, syncer.MENTION); err != nil {
-		return errors.Wrap(err, "unable to sync open mentioned issues")
+
+	for _, query := range completed {
+		if err := i.finishSweep(sweep, query); err != nil {
+			return errors.Wrap(err, "Error syncing issues")
+		}
 	}
 	return nil
 }
 
-func (i *issueSyncer) syncNew(issueNode github.IssueNode, actionConfig trelloWrapper.Actions) error {
-	fmt.Printf("Syncing new issue \"%s\"\n", issueNode.Issue.Title)
-	issue := i.convertIssueNodeToIssue(issueNode)
+// issueQuery names the watermark of the issue search for relation
+func issueQuery(relation resolver.Relation) string {
+	return fmt.Sprintf("issue:%s", relation)
+}
 
-	if err := i.storage.SaveNewIssue(issue); err != nil {
-		return errors.Wrapf(err, "Error syncing new issue \"%s\"", issue.Title)
+// SyncItem syncs a single issue, closing its cards when it was closed or no
+// longer relates to the user
+func (i *issueSyncer) SyncItem(issueId string) error {
+	item, err := i.github.Issues.Get(issueId)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing issue %s", issueId)
 	}
 
-	for _, listName := range actionConfig.Create.Lists {
-		fmt.Printf("\tSyncing new issue for list %s\n", listName)
-
-		card := i.convertIssueToCard(issue, actionConfig.Create.Labels, listName)
-
-		if err := i.createNewCard(card, issue.Comments); err != nil {
-			return err
+	relations := issueRelations(item)
+	if item == nil || item.State != github.OPEN || len(relations) == 0 {
+		if err := i.closeTracked(issueId); err != nil {
+			return errors.Wrapf(err, "Error syncing issue %s", issueId)
 		}
+		return nil
 	}
 
+	if err := i.sync(item.Node, relations); err != nil {
+		return errors.Wrapf(err, "Error syncing issue %s", item.Node.Issue.Title)
+	}
 	return nil
 }
 
-func (i *issueSyncer) createNewCard(storageCard *storage.Card, comments []*storage.Comment) error {
-	// Create corresponding trello card
-	trelloCard, err := i.trello.CreateNewCard(storageCard)
-	if err != nil {
-		return err
+// issueRelations lists the relations of a fetched issue tracked by Sync
+func issueRelations(item *github.IssueItem) []resolver.Relation {
+	relations := []resolver.Relation{}
+	if item == nil {
+		return relations
 	}
-
-	// Save new card
-	if err := i.storage.SaveNewCard(storageCard); err != nil {
-		return errors.Wrapf(err, "Error creating new card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
+	if item.Relations.Assignee {
+		relations = append(relations, resolver.ASSIGNEE)
 	}
-
-	// Sync Issue comments
-	_, err = trelloCard.SyncComments(comments)
-	if err != nil {
-		return err
+	if item.Relations.Mentioned {
+		relations = append(relations, resolver.MENTIONED)
 	}
-
-	return nil
+	return relations
 }
 
-func (i *issueSyncer) convertIssueToCard(issue *storage.Issue, labelNames []string, listName string) *storage.Card {
-	return &storage.Card{
-		IssueId: issue.Id,
-		Title:   issue.Title,
-		Text:    issue.Body,
+func (i *issueSyncer) sync(issueNode github.IssueNode, relations []resolver.Relation) error {
+	fmt.Printf("Syncing issue \"%s\"\n", issueNode.Issue.Title)
+
+	fresh := i.convertIssueNodeToIssue(issueNode)
+	fresh.UserRelationship = joinRelations(relations)
+	actions := i.actions(resolver.ISSUE, relations)
 
-		LabelIds: strings.Join(i.trello.GetLabelIdsForNames(labelNames), ","),
-		ListId:   i.trello.GetListIdForName(listName),
+	issue, err := i.storage.FindIssue(fresh.IssueId)
+	if err != nil {
+		return err
+	}
+	// New issue
+	if issue == nil {
+		fmt.Printf("Syncing new issue \"%s\"\n", fresh.Title)
+		return i.syncNew(fresh, actions)
 	}
+	// Update Existing Issue
+	fmt.Printf("Syncing existing issue \"%s\"\n", fresh.Title)
+	return i.syncExisting(issue, fresh, actions)
 }
 
 func (i *issueSyncer) convertIssueNodeToIssue(issueNode github.IssueNode) *storage.Issue {
-	comments := make([]*storage.Comment, len(issueNode.Issue.Comments.Edges))
-	for idx, commentNode := range issueNode.Issue.Comments.Edges {
-		comments[idx] = &storage.Comment{
-			IssueId: string(issueNode.Issue.ID),
-			Body:    syncer.GenerateComment(commentNode),
-		}
-	}
+	item := syncer.IssueItem(issueNode)
 	return &storage.Issue{
-		Body:       syncer.GenerateCardDesc(string(issueNode.Issue.Body), string(issueNode.Issue.URL)),
+		Body:       i.renderer.CardDesc(item),
+		CardTitle:  i.renderer.CardTitle(item),
+		Due:        i.dueDate(issueNode.Issue.Labels, string(issueNode.Issue.Body), issueNode.Issue.Milestone),
 		IssueId:    string(issueNode.Issue.ID),
+		Labels:     encodeLabels(issueNode.Issue.Labels),
+		Members:    encodeMembers(issueNode.Issue.Assignees.Nodes),
+		Milestone:  string(issueNode.Issue.Milestone.Title),
 		Number:     int64(issueNode.Issue.Number),
 		Repository: string(issueNode.Issue.Repository.Name),
 		Title:      string(issueNode.Issue.Title),
+		Type:       storage.ISSUE,
 		URL:        string(issueNode.Issue.URL),
 
-		Comments: comments,
+		Comments: i.convertCommentNodes(string(issueNode.Issue.ID), issueNode.Issue.Comments.Edges),
 	}
 }
-
-// ==============================================================================
-
-//
-//func (i *issueSyncer) syncClosed() error {
-//	fmt.Println("Syncing closed issues")
-//	cards, err := i.trello.GetCardInstancesByName()
-//	if err != nil {
-//		return errors.Wrap(err, "unable to sync closed issues")
-//	}
-//
-//	assignedIssues, err := i.github.Issues.Assigned()
-//	if err != nil {
-//		return errors.Wrap(err, "unable to sync closed issues")
-//	}
-//	if err = i.close(assignedIssues, cards, i.config.Relationship.Assignee.Actions); err != nil {
-//		return errors.Wrap(err, "unable to sync closed issues")
-//	}
-//
-//	mentionedIssues, err := i.github.Issues.Mentioned()
-//	if err != nil {
-//		return errors.Wrap(err, "unable to sync closed issues")
-//	}
-//	if err = i.close(mentionedIssues, cards, i.config.Relationship.Mention.Actions); err != nil {
-//		return errors.Wrap(err, "unable to sync closed issues")
-//	}
-//	return nil
-//}
-//
-//func (i *issueSyncer) close(
-//	issues []github.IssueNode,
-//	cardMap map[string][]*trelloWrapper.Card,
-//	actionConfig trelloWrapper.Actions,
-//) error {
-//	issueMap := i.getIssuesByCardName(issues)
-//	for cardName, cards := range cardMap {
-//		// card isn't in active issues so close
-//		if _, ok := issueMap[cardName]; !ok {
-//			if cardName != i.trello.LabelCardName() {
-//				fmt.Printf("closing issue \"%s\"\n", cardName)
-//				i.trello.CloseCards(cards, actionConfig)
-//			}
-//		}
-//	}
-//	return nil
-//}
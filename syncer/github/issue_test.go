@@ -0,0 +1,45 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyncKeepsRelationsOfDeferredSearches(t *testing.T) {
+	s := newTestSync(t)
+	defer s.close()
+
+	s.gitHub.assigned = []string{issueJSON("issue", "Issue")}
+	s.gitHub.mentioned = []string{issueJSON("issue", "Issue")}
+	if err := s.issues.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if lists := s.cardLists(t, "issue"); len(lists) != 3 {
+		t.Fatalf("expected cards on doing, review and mentions, got %v", lists)
+	}
+
+	// the assigned search leaves too few points for the low priority
+	// mentioned search, which is deferred
+	s.gitHub.rateLimit = `{"cost": 1, "remaining": 100, "resetAt": "2030-01-02T15:04:05Z"}`
+	s.gitHub.assigned = []string{issueJSON("issue", "Issue renamed")}
+	s.trello.changes = nil
+	if err := s.issues.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	issue, err := s.storage.FindIssue("issue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.UserRelationship != "assignee,mentioned" {
+		t.Errorf("expected the mentioned relation to be kept, got %q", issue.UserRelationship)
+	}
+	if issue.Title != "Issue renamed" {
+		t.Errorf("expected the issue to be updated, got %q", issue.Title)
+	}
+	for _, change := range s.trello.changes {
+		if strings.Contains(change, "idList") {
+			t.Errorf("expected no card to move, got %s", change)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
+	"github.com/luccacabra/github-to-trello/storage"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+)
+
+// encodeLabels stores the labels of a GitHub item, empty when it has none
+func encodeLabels(labels github.LabelConnection) string {
+	if len(labels.Nodes) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(gitHubLabels(labels))
+	return string(encoded)
+}
+
+func gitHubLabels(labels github.LabelConnection) []resolver.Label {
+	converted := make([]resolver.Label, len(labels.Nodes))
+	for idx, label := range labels.Nodes {
+		converted[idx] = resolver.Label{Name: string(label.Name), Color: string(label.Color)}
+	}
+	return converted
+}
+
+func decodeLabels(encoded string) []resolver.Label {
+	labels := []resolver.Label{}
+	if len(encoded) > 0 {
+		json.Unmarshal([]byte(encoded), &labels)
+	}
+	return labels
+}
+
+// gitHubLabelIds returns the IDs of the trello labels mapped from the GitHub
+// labels and milestone stored for an item
+func (c *cardSyncer) gitHubLabelIds(issue *storage.Issue, create bool) ([]string, error) {
+	mapped := c.resolver.GitHubLabels(decodeLabels(issue.Labels), issue.Milestone)
+	labels := make([]trelloWrapper.GitHubLabel, len(mapped))
+	for idx, label := range mapped {
+		labels[idx] = trelloWrapper.GitHubLabel{Name: label.Name, Color: label.Color}
+	}
+
+	labelIds, missing, err := c.trello.GitHubLabelIds(labels, create && c.resolver.CreateGitHubLabels())
+	if err != nil {
+		return nil, err
+	}
+	if create && len(missing) > 0 {
+		fmt.Printf("\t[WARNING] No trello labels for GitHub labels %s\n", strings.Join(missing, ", "))
+	}
+	return labelIds, nil
+}
+
+// labelUpdate moves a card's labels from the GitHub labels it was synced with
+// to the current ones, only labels that changed on GitHub are touched
+type labelUpdate struct {
+	add    []string
+	remove []string
+}
+
+func (c *cardSyncer) gitHubLabelUpdate(stored, fresh *storage.Issue) (labelUpdate, error) {
+	if stored.Labels == fresh.Labels && stored.Milestone == fresh.Milestone {
+		return labelUpdate{}, nil
+	}
+
+	current, err := c.gitHubLabelIds(fresh, true)
+	if err != nil {
+		return labelUpdate{}, err
+	}
+	previous, err := c.gitHubLabelIds(stored, false)
+	if err != nil {
+		return labelUpdate{}, err
+	}
+	return labelUpdate{
+		add:    subtractIds(current, previous),
+		remove: subtractIds(previous, current),
+	}, nil
+}
+
+// subtractIds returns the IDs in ids that aren't in other
+func subtractIds(ids, other []string) []string {
+	excluded := map[string]bool{}
+	for _, id := range other {
+		excluded[id] = true
+	}
+	remaining := []string{}
+	for _, id := range ids {
+		if !excluded[id] {
+			remaining = append(remaining, id)
+		}
+	}
+	return remaining
+}
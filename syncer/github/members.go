@@ -0,0 +1,73 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/luccacabra/github-to-trello/github"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+)
+
+// encodeMembers stores the users assigned to or requested to review a GitHub
+// item, empty when there are none
+func encodeMembers(users []github.User) string {
+	members := []trelloWrapper.GitHubUser{}
+	seen := map[string]bool{}
+	for _, user := range users {
+		if seen[string(user.Login)] {
+			continue
+		}
+		seen[string(user.Login)] = true
+		members = append(members, trelloWrapper.GitHubUser{Login: string(user.Login), Name: string(user.Name)})
+	}
+	if len(members) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(members)
+	return string(encoded)
+}
+
+func decodeMembers(encoded string) []trelloWrapper.GitHubUser {
+	members := []trelloWrapper.GitHubUser{}
+	if len(encoded) > 0 {
+		json.Unmarshal([]byte(encoded), &members)
+	}
+	return members
+}
+
+// gitHubMemberIds returns the IDs of the board members for the GitHub users
+// stored for an item
+func (c *cardSyncer) gitHubMemberIds(encoded string, warn bool) []string {
+	memberIds, missing := c.trello.GitHubMemberIds(decodeMembers(encoded))
+	if warn && len(missing) > 0 {
+		fmt.Printf("\t[WARNING] No trello members for GitHub users %s\n", strings.Join(missing, ", "))
+	}
+	return memberIds
+}
+
+// memberUpdate moves a card's members from the GitHub users it was synced with
+// to the current ones
+type memberUpdate struct {
+	add    []string
+	remove []string
+}
+
+func (c *cardSyncer) gitHubMemberUpdate(stored, fresh string) memberUpdate {
+	if stored == fresh {
+		return memberUpdate{}
+	}
+
+	add := c.gitHubMemberIds(fresh, true)
+	current := map[string]bool{}
+	for _, memberId := range add {
+		current[memberId] = true
+	}
+	remove := []string{}
+	for _, memberId := range c.gitHubMemberIds(stored, false) {
+		if !current[memberId] {
+			remove = append(remove, memberId)
+		}
+	}
+	return memberUpdate{add: add, remove: remove}
+}
@@ -1 +1,201 @@
 package github
+
+import (
+	"fmt"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
+	"github.com/luccacabra/github-to-trello/storage"
+	"github.com/luccacabra/github-to-trello/syncer"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+
+	"github.com/pkg/errors"
+)
+
+var _ syncer.Syncer = (*pullRequestSyncer)(nil)
+var _ syncer.ItemSyncer = (*pullRequestSyncer)(nil)
+
+type pullRequestSyncer struct {
+	cardSyncer
+}
+
+func NewPullRequestSyncer(
+	githubClient *github.Client,
+	trello *trelloWrapper.Client,
+	storage *storage.Storage,
+	resolver *resolver.Resolver,
+	renderer *syncer.Renderer,
+) (o *pullRequestSyncer) {
+	return &pullRequestSyncer{
+		cardSyncer: cardSyncer{
+			trello:   trello,
+			github:   githubClient,
+			storage:  storage,
+			resolver: resolver,
+			renderer: renderer,
+		},
+	}
+}
+
+func (p *pullRequestSyncer) Sync() error {
+	pullRequests := []github.PullRequestNode{}
+	relations := map[string][]resolver.Relation{}
+
+	searches := []struct {
+		relation resolver.Relation
+		search   func() ([]github.PullRequestNode, error)
+	}{
+		{resolver.ASSIGNEE, p.github.PullRequests.Assigned},
+		{resolver.AUTHOR, p.github.PullRequests.Authored},
+		{resolver.REVIEW_REQUESTED_USER, p.github.PullRequests.ReviewRequested},
+		{resolver.REVIEW_REQUESTED_TEAM, p.github.PullRequests.TeamReviewRequested},
+		{resolver.MENTIONED, p.github.PullRequests.Mentioned},
+	}
+	// a deferred search leaves the run incomplete, its items may still be open
+	complete := true
+	for _, s := range searches {
+		pullRequestNodes, err := s.search()
+		if github.IsDeferred(err) {
+			fmt.Printf("[WARNING] Skipping %s pull requests: %s\n", s.relation, errors.Cause(err))
+			complete = false
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Error syncing open %s pull requests", s.relation)
+		}
+		fmt.Printf("Found %d %s pull requests\n", len(pullRequestNodes), s.relation)
+
+		for _, pullRequestNode := range pullRequestNodes {
+			// graphql API returns empty nodes for non pull request results
+			if len(pullRequestNode.PullRequest.Title) == 0 {
+				continue
+			}
+			pullRequestId := string(pullRequestNode.PullRequest.ID)
+			if _, ok := relations[pullRequestId]; !ok {
+				pullRequests = append(pullRequests, pullRequestNode)
+			}
+			relations[pullRequestId] = append(relations[pullRequestId], s.relation)
+		}
+	}
+
+	searched := make([]resolver.Relation, len(searches))
+	for idx, s := range searches {
+		searched[idx] = s.relation
+	}
+	for _, pullRequestNode := range pullRequests {
+		pullRequestId := string(pullRequestNode.PullRequest.ID)
+		pullRequestRelations := relations[pullRequestId]
+		// relations of deferred searches are kept as they were
+		if !complete {
+			var err error
+			if pullRequestRelations, err = p.mergeRelations(pullRequestId, pullRequestRelations, searched); err != nil {
+				return errors.Wrapf(err, "Error syncing pull request %s", pullRequestNode.PullRequest.Title)
+			}
+		}
+		if err := p.sync(pullRequestNode, pullRequestRelations); err != nil {
+			return errors.Wrapf(err, "Error syncing pull request %s", pullRequestNode.PullRequest.Title)
+		}
+	}
+
+	if !complete {
+		fmt.Printf("[WARNING] Not closing pull requests, some searches were deferred\n")
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for pullRequestId := range relations {
+		seen[pullRequestId] = true
+	}
+	if err := p.syncClosed(storage.PULL_REQUEST, seen); err != nil {
+		return errors.Wrap(err, "Error syncing closed pull requests")
+	}
+	return nil
+}
+
+// SyncItem syncs a single pull request, closing its cards when it was closed,
+// merged or no longer relates to the user
+func (p *pullRequestSyncer) SyncItem(pullRequestId string) error {
+	item, err := p.github.PullRequests.Get(pullRequestId)
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing pull request %s", pullRequestId)
+	}
+
+	relations := pullRequestRelations(item)
+	if item == nil || item.State != github.OPEN || len(relations) == 0 {
+		if err := p.closeTracked(pullRequestId); err != nil {
+			return errors.Wrapf(err, "Error syncing pull request %s", pullRequestId)
+		}
+		return nil
+	}
+
+	if err := p.sync(item.Node, relations); err != nil {
+		return errors.Wrapf(err, "Error syncing pull request %s", item.Node.PullRequest.Title)
+	}
+	return nil
+}
+
+// pullRequestRelations lists the relations of a fetched pull request tracked by Sync
+func pullRequestRelations(item *github.PullRequestItem) []resolver.Relation {
+	relations := []resolver.Relation{}
+	if item == nil {
+		return relations
+	}
+	// same order as the searches in Sync
+	for _, r := range []struct {
+		matches  bool
+		relation resolver.Relation
+	}{
+		{item.Relations.Assignee, resolver.ASSIGNEE},
+		{item.Relations.Author, resolver.AUTHOR},
+		{item.Relations.ReviewRequested, resolver.REVIEW_REQUESTED_USER},
+		{item.Relations.TeamReviewRequested, resolver.REVIEW_REQUESTED_TEAM},
+		{item.Relations.Mentioned, resolver.MENTIONED},
+	} {
+		if r.matches {
+			relations = append(relations, r.relation)
+		}
+	}
+	return relations
+}
+
+func (p *pullRequestSyncer) sync(pullRequestNode github.PullRequestNode, relations []resolver.Relation) error {
+	fmt.Printf("Syncing pull request \"%s\"\n", pullRequestNode.PullRequest.Title)
+
+	fresh := p.convertPullRequestNodeToIssue(pullRequestNode)
+	fresh.UserRelationship = joinRelations(relations)
+	actions := p.actions(resolver.PULL_REQUEST, relations)
+
+	pullRequest, err := p.storage.FindIssue(fresh.IssueId)
+	if err != nil {
+		return err
+	}
+	// New pull request
+	if pullRequest == nil {
+		fmt.Printf("Syncing new pull request \"%s\"\n", fresh.Title)
+		return p.syncNew(fresh, actions)
+	}
+	// Update existing pull request
+	fmt.Printf("Syncing existing pull request \"%s\"\n", fresh.Title)
+	return p.syncExisting(pullRequest, fresh, actions)
+}
+
+func (p *pullRequestSyncer) convertPullRequestNodeToIssue(pullRequestNode github.PullRequestNode) *storage.Issue {
+	pr := pullRequestNode.PullRequest
+	item := syncer.PullRequestItem(pullRequestNode)
+	return &storage.Issue{
+		Body:       p.renderer.CardDesc(item),
+		CardTitle:  p.renderer.CardTitle(item),
+		Due:        p.dueDate(pr.Labels, string(pr.Body), pr.Milestone),
+		IssueId:    string(pr.ID),
+		Labels:     encodeLabels(pr.Labels),
+		Members:    encodeMembers(append(pr.Assignees.Nodes, pr.ReviewRequests.Users()...)),
+		Milestone:  string(pr.Milestone.Title),
+		Number:     int64(pr.Number),
+		Repository: string(pr.Repository.Name),
+		Title:      string(pr.Title),
+		Type:       storage.PULL_REQUEST,
+		URL:        string(pr.URL),
+
+		Comments: p.convertCommentNodes(string(pr.ID), pr.Comments.Edges),
+	}
+}
@@ -0,0 +1,250 @@
+package github
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
+	"github.com/luccacabra/github-to-trello/storage"
+	"github.com/luccacabra/github-to-trello/syncer"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+// Orphan is a synced card whose issue no longer matches the sync
+type Orphan struct {
+	TrelloCardId string
+	Title        string
+	Reason       string
+}
+
+type ReconcileReport struct {
+	// Cards is the number of synced cards found on the board
+	Cards int
+	// Issues is the number of issues and pull requests restored from them
+	Issues  int
+	Orphans []Orphan
+	// Gaps are tracked open issues without a card on the board
+	Gaps []*storage.Issue
+}
+
+func (r *ReconcileReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Reconciled %d issues from %d synced cards\n", r.Issues, r.Cards)
+	for _, orphan := range r.Orphans {
+		fmt.Fprintf(w, "\t[ORPHAN] card \"%s\" (%s): %s\n", orphan.Title, orphan.TrelloCardId, orphan.Reason)
+	}
+	for _, gap := range r.Gaps {
+		fmt.Fprintf(w, "\t[GAP] %s \"%s\" has no card: %s\n", gap.Type, gap.Title, gap.URL)
+	}
+}
+
+// Reconciler restores issues, cards and comment mappings from the synced
+// cards on the board
+type Reconciler struct {
+	issues       *issueSyncer
+	pullRequests *pullRequestSyncer
+}
+
+func NewReconciler(
+	githubClient *github.Client,
+	trello *trelloWrapper.Client,
+	storage *storage.Storage,
+	resolver *resolver.Resolver,
+	renderer *syncer.Renderer,
+) *Reconciler {
+	return &Reconciler{
+		issues:       NewIssueSyncer(githubClient, trello, storage, resolver, renderer, 0),
+		pullRequests: NewPullRequestSyncer(githubClient, trello, storage, resolver, renderer),
+	}
+}
+
+func (r *Reconciler) Reconcile() (*ReconcileReport, error) {
+	c := &r.issues.cardSyncer
+	report := &ReconcileReport{}
+
+	boardCards, err := c.trello.BoardCards(trelloWrapper.OpenCards)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reconciling board")
+	}
+
+	// group synced cards by the node ID of their issue
+	order := []string{}
+	cardsById := map[string][]*trello.Card{}
+	for _, card := range boardCards {
+		gitHubId, gitHubURL := trelloWrapper.CardIdentity(card)
+		if len(gitHubId) == 0 && len(gitHubURL) > 0 {
+			if gitHubId, err = c.github.ResolveURL(gitHubURL); err != nil {
+				return nil, errors.Wrapf(err, "Error reconciling card \"%s\"", card.Name)
+			}
+			if len(gitHubId) == 0 {
+				report.Orphans = append(report.Orphans, Orphan{card.ID, card.Name, "issue not found"})
+				continue
+			}
+		}
+		if len(gitHubId) == 0 {
+			continue
+		}
+		report.Cards++
+		if _, ok := cardsById[gitHubId]; !ok {
+			order = append(order, gitHubId)
+		}
+		cardsById[gitHubId] = append(cardsById[gitHubId], card)
+	}
+
+	for _, gitHubId := range order {
+		if err := r.reconcileIssue(gitHubId, cardsById[gitHubId], report); err != nil {
+			return nil, errors.Wrapf(err, "Error reconciling %s", gitHubId)
+		}
+	}
+
+	for _, issueType := range []storage.IssueType{storage.ISSUE, storage.PULL_REQUEST} {
+		issues, err := c.storage.FindOpenIssues(issueType)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reconciling board")
+		}
+		for _, issue := range issues {
+			if _, ok := cardsById[issue.IssueId]; !ok {
+				report.Gaps = append(report.Gaps, issue)
+			}
+		}
+	}
+	return report, nil
+}
+
+// reconcileIssue stores the issue behind a group of cards, the cards and
+// their comment mappings. Issues that no longer match stay open in storage so
+// the next sync closes their cards.
+func (r *Reconciler) reconcileIssue(gitHubId string, cards []*trello.Card, report *ReconcileReport) error {
+	c := &r.issues.cardSyncer
+
+	fresh, open, err := r.fetch(gitHubId)
+	if err != nil {
+		return err
+	}
+	if fresh == nil {
+		for _, card := range cards {
+			report.Orphans = append(report.Orphans, Orphan{card.ID, card.Name, "issue not found"})
+		}
+		return nil
+	}
+	if !open || len(fresh.UserRelationship) == 0 {
+		reason := "no longer relates to the user"
+		if !open {
+			reason = "closed"
+		}
+		for _, card := range cards {
+			report.Orphans = append(report.Orphans, Orphan{card.ID, card.Name, reason})
+		}
+	}
+
+	fmt.Printf("Reconciling %s \"%s\" from %d cards\n", fresh.Type, fresh.Title, len(cards))
+	stored, err := c.storage.FindIssue(gitHubId)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		err = c.storage.SaveNewIssue(fresh)
+	} else {
+		fresh.Id = stored.Id
+		err = c.storage.UpdateIssue(fresh)
+	}
+	if err != nil {
+		return err
+	}
+	report.Issues++
+
+	for _, card := range cards {
+		if err := r.reconcileCard(fresh, card); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch converts the issue or pull request with the given node ID, nil if it no longer exists
+func (r *Reconciler) fetch(gitHubId string) (*storage.Issue, bool, error) {
+	issueItem, err := r.issues.github.Issues.Get(gitHubId)
+	if err != nil {
+		return nil, false, err
+	}
+	if issueItem != nil {
+		issue := r.issues.convertIssueNodeToIssue(issueItem.Node)
+		issue.UserRelationship = joinRelations(issueRelations(issueItem))
+		return issue, issueItem.State == github.OPEN, nil
+	}
+
+	pullRequestItem, err := r.pullRequests.github.PullRequests.Get(gitHubId)
+	if err != nil {
+		return nil, false, err
+	}
+	if pullRequestItem != nil {
+		issue := r.pullRequests.convertPullRequestNodeToIssue(pullRequestItem.Node)
+		issue.UserRelationship = joinRelations(pullRequestRelations(pullRequestItem))
+		return issue, pullRequestItem.State == github.OPEN, nil
+	}
+	return nil, false, nil
+}
+
+// reconcileCard stores the card instance and maps the comments synced onto it
+func (r *Reconciler) reconcileCard(issue *storage.Issue, card *trello.Card) error {
+	c := &r.issues.cardSyncer
+
+	storageCard := trelloWrapper.StorageCard(card, issue.IssueId)
+	storageCard.IssueId = issue.Id
+
+	stored, err := c.storage.FindCardByTrelloId(card.ID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		err = c.storage.SaveNewCard(storageCard)
+	} else {
+		storageCard.Id = stored.Id
+		_, err = c.storage.UpdateCard(storageCard)
+	}
+	if err != nil {
+		return err
+	}
+
+	trelloCard := c.trello.NewCard(storageCard)
+	// cards recognised by their link are identified from now on
+	if gitHubId, _ := trelloWrapper.CardIdentity(card); len(gitHubId) == 0 {
+		if err := trelloCard.AttachIdentity(issue.URL); err != nil {
+			return err
+		}
+	}
+
+	actions, err := trelloCard.GetComments()
+	if err != nil {
+		return errors.Wrapf(err, "Error getting comments for card \"%s\"", card.ID)
+	}
+	// synced comments are matched to their GitHub comment by text, those left
+	// unmatched are deleted on the next comment sync
+	unmatched := append([]*storage.Comment{}, issue.Comments...)
+	comments := []*storage.Comment{}
+	// trello returns newest comments first
+	for idx := len(actions) - 1; idx >= 0; idx-- {
+		action := actions[idx]
+		if action.Data == nil || !trelloWrapper.IsSyncedComment(action.Data.Text) {
+			continue
+		}
+		comment := &storage.Comment{
+			IssueId:        issue.IssueId,
+			TrelloActionId: action.ID,
+			Body:           action.Data.Text,
+		}
+		for i, githubComment := range unmatched {
+			if githubComment.Body == comment.Body {
+				comment.GitHubId = githubComment.GitHubId
+				comment.UpdatedAt = githubComment.UpdatedAt
+				unmatched = append(unmatched[:i], unmatched[i+1:]...)
+				break
+			}
+		}
+		comments = append(comments, comment)
+	}
+	return c.storage.ReplaceCardComments(storageCard.Id, comments)
+}
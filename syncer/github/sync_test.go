@@ -0,0 +1,226 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/resolver"
+	"github.com/luccacabra/github-to-trello/storage"
+	"github.com/luccacabra/github-to-trello/syncer"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+)
+
+const testRateLimit = `{"cost": 1, "remaining": 4000, "resetAt": "2030-01-02T15:04:05Z"}`
+
+// testConfig puts assigned issues on two lists and mentioning ones on a third
+var testConfig = &resolver.Config{
+	Lists: []string{"Inbox"},
+	SyncActions: map[string]resolver.ActionConfig{
+		string(resolver.OPEN): {
+			IssueTypes: map[string]resolver.IssueTypeConfig{
+				string(resolver.ISSUE): {
+					UserRelationship: resolver.UserRelationshipConfig{
+						Assignee:  resolver.Target{Lists: []string{"Doing", "Review"}},
+						Mentioned: resolver.Target{Lists: []string{"Mentions"}},
+					},
+				},
+			},
+		},
+	},
+}
+
+// fakeGitHub answers the assigned and mentioned issue searches with the
+// issues set for them, along with the rate limit set for the search
+type fakeGitHub struct {
+	mu        sync.Mutex
+	assigned  []string
+	mentioned []string
+	rateLimit string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query, _ := request.Variables["searchQuery"].(string)
+	issues := []string{}
+	switch {
+	case !strings.Contains(request.Query, "search("):
+		http.Error(w, "unexpected query "+request.Query, http.StatusBadRequest)
+		return
+	case strings.Contains(query, "assignee:"):
+		issues = f.assigned
+	case strings.Contains(query, "mentions:"):
+		issues = f.mentioned
+	}
+
+	rateLimit := f.rateLimit
+	if len(rateLimit) == 0 {
+		rateLimit = testRateLimit
+	}
+	fmt.Fprintf(
+		w,
+		`{"data": {"rateLimit": %s, "search": {"edges": [%s], "pageInfo": {"hasNextPage": false}}}}`,
+		rateLimit,
+		strings.Join(issues, ", "),
+	)
+}
+
+// issueJSON is a search edge for an open issue without comments or labels
+func issueJSON(id, title string) string {
+	return fmt.Sprintf(
+		`{"node": {"id": %q, "title": %q, "state": "OPEN", "url": "https://github.com/org/repo/issues/1", `+
+			`"comments": {"edges": [], "pageInfo": {"hasNextPage": false}}, `+
+			`"labels": {"nodes": [], "pageInfo": {"hasNextPage": false}}}}`,
+		id,
+		title,
+	)
+}
+
+// fakeTrello serves a board with the lists of testConfig, creating cards on
+// request and recording every change made to them
+type fakeTrello struct {
+	mu      sync.Mutex
+	created int
+	// failCreate fails the creation of the card with this number, counting from 1
+	failCreate int
+	changes    []string
+}
+
+func (f *fakeTrello) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/search":
+		fmt.Fprint(w, `{"boards": [{"id": "board", "name": "Board"}]}`)
+	case r.URL.Path == "/boards/board/lists":
+		fmt.Fprint(w, `[{"id": "inbox", "name": "Inbox"}, {"id": "doing", "name": "Doing"}, `+
+			`{"id": "review", "name": "Review"}, {"id": "mentions", "name": "Mentions"}]`)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/actions"),
+		r.URL.Path == "/boards/board/labels",
+		r.URL.Path == "/boards/board/cards":
+		fmt.Fprint(w, `[]`)
+	case r.Method == http.MethodPost && r.URL.Path == "/cards":
+		f.created++
+		if f.created == f.failCreate {
+			http.Error(w, "invalid list", http.StatusBadRequest)
+			return
+		}
+		f.changes = append(f.changes, fmt.Sprintf("create card%d on %s", f.created, r.Form.Get("idList")))
+		fmt.Fprintf(w, `{"id": "card%d"}`, f.created)
+	case r.Method == http.MethodPut:
+		f.changes = append(f.changes, fmt.Sprintf("update %s %s", r.URL.Path, r.Form.Encode()))
+		fmt.Fprint(w, `{}`)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+// testSync holds an issue syncer wired to fake GitHub and trello servers and
+// a throwaway database
+type testSync struct {
+	gitHub  *fakeGitHub
+	trello  *fakeTrello
+	storage *storage.Storage
+	issues  *issueSyncer
+
+	close func()
+}
+
+func newTestSync(t *testing.T) *testSync {
+	dir, err := ioutil.TempDir("", "syncer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSync{gitHub: &fakeGitHub{}, trello: &fakeTrello{}}
+	gitHubServer := httptest.NewServer(s.gitHub)
+	trelloServer := httptest.NewServer(s.trello)
+	s.storage = storage.Init(storage.Config{Path: filepath.Join(dir, "test.db")})
+	s.close = func() {
+		s.storage.Close()
+		gitHubServer.Close()
+		trelloServer.Close()
+		os.RemoveAll(dir)
+	}
+
+	renderer, err := syncer.NewRenderer(syncer.Templates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.issues = NewIssueSyncer(
+		github.NewClient("token", github.Config{OrgName: "org", UserName: "octocat", BaseURL: gitHubServer.URL}),
+		trelloWrapper.NewClient("key", "token", trelloWrapper.ClientConfig{BoardName: "Board", BaseURL: trelloServer.URL}),
+		s.storage,
+		resolver.New(testConfig),
+		renderer,
+		0,
+	)
+	return s
+}
+
+// cardLists returns the lists of the cards stored for an issue
+func (s *testSync) cardLists(t *testing.T, issueId string) []string {
+	issue, err := s.storage.FindIssue(issueId)
+	if err != nil || issue == nil {
+		t.Fatalf("expected issue %s to be stored, got %v", issueId, err)
+	}
+	cards, err := s.storage.FindCards(issue.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists := make([]string, len(cards))
+	for idx, card := range cards {
+		lists[idx] = card.ListId
+	}
+	return lists
+}
+
+func TestSyncCreatesMissingCards(t *testing.T) {
+	s := newTestSync(t)
+	defer s.close()
+
+	s.gitHub.assigned = []string{issueJSON("issue", "Issue")}
+	// the card on the second list fails to be created
+	s.trello.failCreate = 2
+	if err := s.issues.Sync(); err == nil {
+		t.Fatal("expected the failed card creation to fail the sync")
+	}
+	if lists := s.cardLists(t, "issue"); len(lists) != 1 {
+		t.Fatalf("expected a single card after the failure, got %v", lists)
+	}
+
+	if err := s.issues.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	lists := s.cardLists(t, "issue")
+	if len(lists) != 2 || lists[0] != "doing" || lists[1] != "review" {
+		t.Errorf("expected cards on doing and review, got %v", lists)
+	}
+
+	// nothing is left to create once every list has its card
+	if err := s.issues.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if s.trello.created != 3 {
+		t.Errorf("expected 3 card creations, got %d", s.trello.created)
+	}
+}
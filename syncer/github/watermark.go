@@ -0,0 +1,82 @@
+package github
+
+import (
+	"time"
+
+	"github.com/luccacabra/github-to-trello/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSweepInterval = 24 * time.Hour
+
+	// searchLag is taken off every watermark, GitHub's search index trails
+	// behind the updates it reports
+	searchLag = 5 * time.Minute
+)
+
+// sweep is a single run over a set of watermarked searches. Searches only ask
+// for items updated since their watermark, unless the run is a full sweep.
+// Full sweeps catch the deletions and relationship changes that never bump an
+// item's updatedAt.
+type sweep struct {
+	started time.Time
+	full    bool
+
+	watermarks map[string]*storage.Watermark
+}
+
+// startSweep loads the watermarks of queries, running a full sweep when one of
+// them never completed a full run or the oldest full run is over interval ago
+func (c *cardSyncer) startSweep(queries []string, interval time.Duration) (*sweep, error) {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	s := &sweep{
+		started:    time.Now(),
+		watermarks: map[string]*storage.Watermark{},
+	}
+	for _, query := range queries {
+		watermark, err := c.storage.FindWatermark(query)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error loading watermark of %s", query)
+		}
+		if watermark == nil {
+			watermark = &storage.Watermark{Query: query}
+		}
+		s.watermarks[query] = watermark
+
+		swept, err := time.Parse(time.RFC3339, watermark.SweptAt)
+		if err != nil || s.started.Sub(swept) > interval {
+			s.full = true
+		}
+	}
+	return s, nil
+}
+
+// since returns the watermark of query, zero during a full sweep
+func (s *sweep) since(query string) time.Time {
+	if s.full {
+		return time.Time{}
+	}
+	since, err := time.Parse(time.RFC3339, s.watermarks[query].SyncedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return since
+}
+
+// finishSweep advances the watermark of a query whose results were all synced
+func (c *cardSyncer) finishSweep(s *sweep, query string) error {
+	watermark := s.watermarks[query]
+	watermark.SyncedAt = s.started.Add(-searchLag).UTC().Format(time.RFC3339)
+	if s.full {
+		watermark.SweptAt = watermark.SyncedAt
+	}
+	if err := c.storage.SaveWatermark(watermark); err != nil {
+		return errors.Wrapf(err, "Error saving watermark of %s", query)
+	}
+	return nil
+}
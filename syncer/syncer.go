@@ -2,55 +2,77 @@ package syncer
 
 import (
 	"fmt"
-	"strings"
-
-	"github.com/luccacabra/github-to-trello/github"
-	"github.com/luccacabra/github-to-trello/trello"
+	"regexp"
+	"unicode/utf16"
 )
 
-type UserRelationship int
-
-const (
-	ASSIGNEE UserRelationship = iota
-	MENTION
-)
-
-type Config struct {
-	Issue IssueConfig
-}
-type IssueConfig struct {
-	Relationship Relationship
-}
-type Relationship struct {
-	Assignee struct {
-		Actions trello.Actions
-	}
-	Mention struct {
-		Actions trello.Actions
-	}
-}
-
 type Syncer interface {
 	Sync() error
 }
 
-func GenerateCardName(title, repositoryName string) string {
-	return fmt.Sprintf("**[github/%s]** %s", repositoryName, title)
+// ItemSyncer syncs a single issue or pull request identified by its GitHub node ID
+type ItemSyncer interface {
+	SyncItem(id string) error
 }
 
-func GenerateComment(commentNode github.CommentNode) string {
-	comment := commentNode.Node
+// trelloCommentMarker tags GitHub comments mirrored from trello so they are
+// not synced back onto the card
+const trelloCommentMarker = "<!-- github-to-trello:trello-action:%s -->"
 
-	return fmt.Sprintf("## @%s\n\n> %s \n\n___\n\n[View on GitHub](%s)",
-		comment.Author.Login,
-		strings.Replace(string(comment.Body), "\n", "\n> ", -1),
-		comment.URL,
+var trelloCommentPattern = regexp.MustCompile(`<!-- github-to-trello:trello-action:[0-9a-f]+ -->`)
+
+func GenerateTrelloComment(actionId, author, cardURL, text string) string {
+	return fmt.Sprintf("**%s** commented on [Trello](%s):\n\n%s\n\n"+trelloCommentMarker,
+		author,
+		cardURL,
+		text,
+		actionId,
 	)
 }
 
-func GenerateCardDesc(issueBody, URL string) string {
-	return fmt.Sprintf("%s \n\n___\n\n[View on GitHub](%s)",
-		issueBody,
-		URL,
-	)
+// IsTrelloComment reports whether a GitHub comment was mirrored from trello
+func IsTrelloComment(body string) bool {
+	return trelloCommentPattern.MatchString(body)
+}
+
+// MaxTextLength is the longest card description or comment trello accepts,
+// counted in UTF-16 code units
+const MaxTextLength = 16384
+
+const (
+	textFooter    = " \n\n___\n\n[View on GitHub](%s)"
+	textContinued = "\n\n*… [continued on GitHub](%s)*"
+)
+
+// fitText ends text with a link to URL, cutting it short with a link to
+// the rest on GitHub when it would exceed MaxTextLength
+func fitText(text, URL string) string {
+	footer := fmt.Sprintf(textFooter, URL)
+	if textLength(text)+textLength(footer) <= MaxTextLength {
+		return text + footer
+	}
+
+	continued := fmt.Sprintf(textContinued, URL)
+	return cutText(text, MaxTextLength-textLength(footer)-textLength(continued)) + continued + footer
+}
+
+// textLength counts s the way trello does, in UTF-16 code units
+func textLength(s string) int {
+	length := 0
+	for _, r := range s {
+		length += len(utf16.Encode([]rune{r}))
+	}
+	return length
+}
+
+// cutText returns the longest prefix of s at most max UTF-16 code units long
+func cutText(s string, max int) string {
+	length := 0
+	for idx, r := range s {
+		length += len(utf16.Encode([]rune{r}))
+		if length > max {
+			return s[:idx]
+		}
+	}
+	return s
 }
@@ -0,0 +1,277 @@
+/* Card title, description and comment templates */
+
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/markdown"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTitleTemplate       = "{{.Title}}"
+	defaultDescriptionTemplate = "{{if .PullRequest}}`{{.HeadRef}}` → `{{.BaseRef}}`\n\n{{end}}{{.Body}}"
+	defaultCommentTemplate     = "## @{{.Author}}\n\n{{quote .Body}}"
+)
+
+// Templates configures how cards and comments are rendered, empty templates
+// fall back to the defaults. Descriptions and comments always end with a link
+// to GitHub, it is not part of the template.
+type Templates struct {
+	// Title and Description are rendered with an Item
+	Title       string `mapstructure:"title"`
+	Description string `mapstructure:"description"`
+	// Comment is rendered with a Comment
+	Comment string `mapstructure:"comment"`
+}
+
+// Item is the data title and description templates are rendered with
+type Item struct {
+	PullRequest bool
+	Number      int
+	Title       string
+	// Body is converted to trello markdown
+	Body       string
+	URL        string
+	Org        string
+	Repository string
+	Author     string
+	Labels     []string
+	Milestone  string
+	// DueOn is the milestone's due date, zero when there is none
+	DueOn     time.Time
+	Assignees []string
+	CreatedAt time.Time
+	// HeadRef and BaseRef are only set for pull requests
+	HeadRef string
+	BaseRef string
+}
+
+// Comment is the data comment templates are rendered with
+type Comment struct {
+	Author string
+	// Body is converted to trello markdown
+	Body      string
+	URL       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// quote turns text into a markdown block quote
+	"quote": func(text string) string {
+		return "> " + strings.Replace(text, "\n", "\n> ", -1)
+	},
+}
+
+// Renderer renders cards and comments from the configured templates
+type Renderer struct {
+	title       *template.Template
+	description *template.Template
+	comment     *template.Template
+
+	defaults *Renderer
+}
+
+// NewRenderer parses the configured templates and renders each once with
+// sample data so template errors fail at start up rather than mid sync
+func NewRenderer(config Templates) (*Renderer, error) {
+	defaults, err := newRenderer(Templates{
+		Title:       defaultTitleTemplate,
+		Description: defaultDescriptionTemplate,
+		Comment:     defaultCommentTemplate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Title) == 0 {
+		config.Title = defaultTitleTemplate
+	}
+	if len(config.Description) == 0 {
+		config.Description = defaultDescriptionTemplate
+	}
+	if len(config.Comment) == 0 {
+		config.Comment = defaultCommentTemplate
+	}
+	r, err := newRenderer(config)
+	if err != nil {
+		return nil, err
+	}
+	r.defaults = defaults
+	return r, nil
+}
+
+func newRenderer(config Templates) (*Renderer, error) {
+	r := &Renderer{}
+	for _, t := range []struct {
+		name    string
+		text    string
+		target  **template.Template
+		samples []interface{}
+	}{
+		{"title", config.Title, &r.title, sampleItems},
+		{"description", config.Description, &r.description, sampleItems},
+		{"comment", config.Comment, &r.comment, sampleComments},
+	} {
+		parsed, err := template.New(t.name).Funcs(templateFuncs).Parse(t.text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid %s template", t.name)
+		}
+		for _, sample := range t.samples {
+			if err := parsed.Execute(ioutil.Discard, sample); err != nil {
+				return nil, errors.Wrapf(err, "Invalid %s template", t.name)
+			}
+		}
+		*t.target = parsed
+	}
+	return r, nil
+}
+
+// sampleTime is set on every sample so templates formatting dates are checked
+var sampleTime = time.Date(2018, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+// sampleItems cover both branches of templates switching on .PullRequest
+var sampleItems = []interface{}{
+	Item{
+		PullRequest: true,
+		Number:      1,
+		Title:       "Sample",
+		Body:        "Sample body",
+		URL:         "https://github.com/org/repository/pull/1",
+		Org:         "org",
+		Repository:  "repository",
+		Author:      "author",
+		Labels:      []string{"label"},
+		Milestone:   "milestone",
+		DueOn:       sampleTime,
+		Assignees:   []string{"assignee"},
+		CreatedAt:   sampleTime,
+		HeadRef:     "head",
+		BaseRef:     "base",
+	},
+	Item{
+		Number:     2,
+		Title:      "Sample",
+		Body:       "Sample body",
+		URL:        "https://github.com/org/repository/issues/2",
+		Org:        "org",
+		Repository: "repository",
+		Author:     "author",
+		Labels:     []string{},
+		Assignees:  []string{},
+		CreatedAt:  sampleTime,
+	},
+}
+
+var sampleComments = []interface{}{
+	Comment{
+		Author:    "author",
+		Body:      "Sample comment",
+		URL:       "https://github.com/org/repository/pull/1#issuecomment-1",
+		CreatedAt: sampleTime,
+		UpdatedAt: sampleTime,
+	},
+}
+
+// CardTitle renders the title of the card for item
+func (r *Renderer) CardTitle(item Item) string {
+	return r.render(r.title, r.defaults.title, item)
+}
+
+// CardDesc renders the description of the card for item
+func (r *Renderer) CardDesc(item Item) string {
+	return fitText(r.render(r.description, r.defaults.description, item), item.URL)
+}
+
+// Comment renders a GitHub comment as a card comment
+func (r *Renderer) Comment(comment Comment) string {
+	return fitText(r.render(r.comment, r.defaults.comment, comment), comment.URL)
+}
+
+// render falls back to the default template when the configured one fails on
+// data the start up check didn't cover
+func (r *Renderer) render(t, fallback *template.Template, data interface{}) string {
+	buf := &bytes.Buffer{}
+	err := t.Execute(buf, data)
+	if err == nil {
+		return buf.String()
+	}
+
+	fmt.Printf("[WARNING] Unable to render %s template, using the default: %s\n", t.Name(), err)
+	buf.Reset()
+	if err := fallback.Execute(buf, data); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// IssueItem converts an issue for rendering
+func IssueItem(node github.IssueNode) Item {
+	issue := node.Issue
+	return Item{
+		Number:     int(issue.Number),
+		Title:      string(issue.Title),
+		Body:       markdown.Convert(string(issue.Body), markdown.RepositoryFromURL(string(issue.URL))),
+		URL:        string(issue.URL),
+		Org:        markdown.RepositoryFromURL(string(issue.URL)).Owner,
+		Repository: string(issue.Repository.Name),
+		Author:     string(issue.Author.Login),
+		Labels:     labelNames(issue.Labels),
+		Milestone:  string(issue.Milestone.Title),
+		DueOn:      issue.Milestone.DueOn.Time,
+		Assignees:  issue.Assignees.Logins(),
+		CreatedAt:  issue.CreatedAt.Time,
+	}
+}
+
+// PullRequestItem converts a pull request for rendering
+func PullRequestItem(node github.PullRequestNode) Item {
+	pr := node.PullRequest
+	return Item{
+		PullRequest: true,
+		Number:      int(pr.Number),
+		Title:       string(pr.Title),
+		Body:        markdown.Convert(string(pr.Body), markdown.RepositoryFromURL(string(pr.URL))),
+		URL:         string(pr.URL),
+		Org:         markdown.RepositoryFromURL(string(pr.URL)).Owner,
+		Repository:  string(pr.Repository.Name),
+		Author:      string(pr.Author.Login),
+		Labels:      labelNames(pr.Labels),
+		Milestone:   string(pr.Milestone.Title),
+		DueOn:       pr.Milestone.DueOn.Time,
+		Assignees:   pr.Assignees.Logins(),
+		CreatedAt:   pr.CreatedAt.Time,
+		HeadRef:     string(pr.HeadRefName),
+		BaseRef:     string(pr.BaseRefName),
+	}
+}
+
+// CommentItem converts a comment for rendering
+func CommentItem(node github.CommentNode) Comment {
+	comment := node.Node
+	return Comment{
+		Author:    string(comment.Author.Login),
+		Body:      markdown.Convert(string(comment.Body), markdown.RepositoryFromURL(string(comment.URL))),
+		URL:       string(comment.URL),
+		CreatedAt: comment.CreatedAt.Time,
+		UpdatedAt: comment.UpdatedAt.Time,
+	}
+}
+
+func labelNames(labels github.LabelConnection) []string {
+	names := make([]string, len(labels.Nodes))
+	for idx, label := range labels.Nodes {
+		names[idx] = string(label.Name)
+	}
+	return names
+}
@@ -0,0 +1,54 @@
+package syncer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewRendererChecksEverySample(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		templates Templates
+	}{
+		{
+			name:      "issue branch",
+			templates: Templates{Title: "{{if .PullRequest}}{{.Title}}{{else}}{{.Nope}}{{end}}"},
+		},
+		{
+			name:      "index past issue labels",
+			templates: Templates{Description: "{{index .Labels 0}}"},
+		},
+		{
+			name:      "unknown comment field",
+			templates: Templates{Comment: "{{.CreatedAt.Nope}}"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewRenderer(test.templates); err == nil {
+				t.Error("expected an invalid template error")
+			}
+		})
+	}
+}
+
+func TestNewRendererDates(t *testing.T) {
+	r, err := NewRenderer(Templates{Title: `{{.Title}} ({{.CreatedAt.Format "2006-01-02"}})`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := r.CardTitle(Item{Title: "Fix", CreatedAt: sampleTime})
+	if title != "Fix (2018-01-02)" {
+		t.Errorf("unexpected title %q", title)
+	}
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	r, err := NewRenderer(Templates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := r.Comment(Comment{Author: "octocat", Body: "one\ntwo", URL: "https://github.com/o/r/issues/1#c"})
+	if !strings.HasPrefix(comment, "## @octocat\n\n> one\n> two") {
+		t.Errorf("unexpected comment %q", comment)
+	}
+}
@@ -0,0 +1,326 @@
+/* Two way sync of trello card moves and comments back to GitHub */
+
+package trello
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luccacabra/github-to-trello/github"
+	"github.com/luccacabra/github-to-trello/storage"
+	"github.com/luccacabra/github-to-trello/syncer"
+	trelloWrapper "github.com/luccacabra/github-to-trello/trello"
+	"github.com/luccacabra/github-to-trello/webhook"
+
+	"github.com/pkg/errors"
+)
+
+// commentsWatermark records when comment mirroring was first enabled
+const commentsWatermark = "two_way.comments"
+
+var _ syncer.Syncer = (*cardSyncer)(nil)
+var _ webhook.TrelloSubscriber = (*cardSyncer)(nil)
+
+type Config struct {
+	// DoneLists close the card's issue when it is moved into one of them
+	DoneLists []string `mapstructure:"done_lists"`
+	// Comments mirrors comments made directly on cards to GitHub
+	Comments bool
+}
+
+func (c Config) Enabled() bool {
+	return len(c.DoneLists) > 0 || c.Comments
+}
+
+type cardSyncer struct {
+	trello  *trelloWrapper.Client
+	github  *github.Client
+	storage *storage.Storage
+
+	// mu serialises Sync and webhook events, so a card is never synced by
+	// both at once and its comments are only mirrored once
+	mu sync.Mutex
+
+	config      Config
+	doneListIds map[string]bool
+
+	// commentsSince is when comment mirroring was enabled, older comments
+	// are never mirrored
+	commentsSince time.Time
+}
+
+func NewCardSyncer(
+	githubClient *github.Client,
+	trello *trelloWrapper.Client,
+	storage *storage.Storage,
+	config Config,
+) *cardSyncer {
+	return &cardSyncer{
+		trello:  trello,
+		github:  githubClient,
+		storage: storage,
+		config:  config,
+	}
+}
+
+// Sync pushes list moves and new comments of every tracked card to GitHub
+func (c *cardSyncer) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.loadDoneLists()
+	if err := c.loadCommentsSince(); err != nil {
+		return err
+	}
+
+	cards, err := c.storage.FindAllCards()
+	if err != nil {
+		return errors.Wrap(err, "Error syncing trello cards")
+	}
+	fmt.Printf("Found %d tracked trello cards\n", len(cards))
+
+	for _, card := range cards {
+		if err := c.syncCard(card); err != nil {
+			return errors.Wrapf(err, "Error syncing trello card \"%s\"", card.Title)
+		}
+	}
+	return nil
+}
+
+// HandleTrelloEvent syncs the tracked card a webhook event was made on
+func (c *cardSyncer) HandleTrelloEvent(event webhook.TrelloEvent) error {
+	switch event.(type) {
+	case webhook.CardMovedEvent, webhook.CardArchivedEvent:
+	case webhook.CardCommentedEvent:
+		if !c.config.Comments {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	card, err := c.storage.FindCardByTrelloId(event.Card().ID)
+	if err != nil {
+		return err
+	}
+	if card == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadDoneLists()
+	if err := c.loadCommentsSince(); err != nil {
+		return err
+	}
+	if err := c.syncCard(card); err != nil {
+		return errors.Wrapf(err, "Error syncing trello card \"%s\"", card.Title)
+	}
+	return nil
+}
+
+// loadDoneLists resolves the done list names, which may change after a trello refresh
+func (c *cardSyncer) loadDoneLists() {
+	c.doneListIds = map[string]bool{}
+	for _, listName := range c.config.DoneLists {
+		listId := c.trello.GetListIdForName(listName)
+		if len(listId) == 0 {
+			fmt.Printf("[WARNING] Unknown done list \"%s\"\n", listName)
+			continue
+		}
+		c.doneListIds[listId] = true
+	}
+}
+
+// loadCommentsSince reads when comment mirroring was enabled, recording now on
+// the first run so the comments already on the board aren't posted to GitHub
+func (c *cardSyncer) loadCommentsSince() error {
+	if !c.config.Comments || !c.commentsSince.IsZero() {
+		return nil
+	}
+
+	watermark, err := c.storage.FindWatermark(commentsWatermark)
+	if err != nil {
+		return err
+	}
+	if watermark == nil {
+		watermark = &storage.Watermark{
+			Query:    commentsWatermark,
+			SyncedAt: time.Now().UTC().Format(time.RFC3339),
+		}
+		fmt.Println("Mirroring trello comments made from now on")
+		if err := c.storage.SaveWatermark(watermark); err != nil {
+			return err
+		}
+	}
+
+	since, err := time.Parse(time.RFC3339, watermark.SyncedAt)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid watermark %s", commentsWatermark)
+	}
+	c.commentsSince = since
+	return nil
+}
+
+func (c *cardSyncer) syncCard(storageCard *storage.Card) error {
+	issue, err := c.storage.GetIssue(storageCard.IssueId)
+	if err != nil {
+		return err
+	}
+	if issue == nil {
+		return nil
+	}
+
+	card := c.trello.NewCard(storageCard)
+	trelloCard, err := card.Fetch()
+	if trelloWrapper.IsNotFound(err) {
+		fmt.Printf("[WARNING] Trello card \"%s\" was deleted\n", storageCard.Title)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// archived cards are out of the user's hands
+	if trelloCard.Closed {
+		return nil
+	}
+
+	if trelloCard.IDList != storageCard.ListId {
+		if err := c.syncMove(issue, storageCard, trelloCard.IDList); err != nil {
+			return err
+		}
+	}
+
+	if c.config.Comments {
+		if err := c.syncComments(issue, card, trelloCard.ShortUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncMove closes or reopens the issue when its card crossed into or out of a
+// done list since the last sync. The GitHub sync takes care of the card and
+// stored issue once the new state shows up on GitHub.
+func (c *cardSyncer) syncMove(issue *storage.Issue, storageCard *storage.Card, listId string) error {
+	wasDone := c.doneListIds[storageCard.ListId]
+	isDone := c.doneListIds[listId]
+
+	if wasDone != isDone {
+		status, err := c.github.Issues.Status(issue.IssueId)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !status.Found:
+			fmt.Printf("[WARNING] Unable to find %s \"%s\" on GitHub\n", issue.Type, issue.Title)
+		case isDone && status.State == github.OPEN:
+			fmt.Printf("Card \"%s\" moved to done list, closing %s\n", storageCard.Title, issue.URL)
+			if err := c.closeIssue(issue); err != nil {
+				return err
+			}
+		case wasDone && status.State == github.CLOSED:
+			fmt.Printf("Card \"%s\" moved out of done list, reopening %s\n", storageCard.Title, issue.URL)
+			if err := c.reopenIssue(issue); err != nil {
+				return err
+			}
+		}
+	}
+
+	storageCard.ListId = listId
+	if _, err := c.storage.UpdateCard(storageCard); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *cardSyncer) closeIssue(issue *storage.Issue) error {
+	if plan := c.trello.Plan(); plan != nil {
+		plan.Record(trelloWrapper.Operation{
+			Type:     trelloWrapper.CLOSE_GITHUB_ISSUE,
+			CardName: issue.Title,
+		})
+		return nil
+	}
+
+	if issue.Type == storage.PULL_REQUEST {
+		return c.github.PullRequests.Close(issue.IssueId)
+	}
+	return c.github.Issues.Close(issue.IssueId)
+}
+
+func (c *cardSyncer) reopenIssue(issue *storage.Issue) error {
+	if plan := c.trello.Plan(); plan != nil {
+		plan.Record(trelloWrapper.Operation{
+			Type:     trelloWrapper.REOPEN_GITHUB_ISSUE,
+			CardName: issue.Title,
+		})
+		return nil
+	}
+
+	if issue.Type == storage.PULL_REQUEST {
+		return c.github.PullRequests.Reopen(issue.IssueId)
+	}
+	return c.github.Issues.Reopen(issue.IssueId)
+}
+
+// syncComments posts comments made directly on the card to GitHub. Comments
+// mirrored from GitHub are skipped, as are comments already posted and those
+// made before mirroring was enabled.
+func (c *cardSyncer) syncComments(issue *storage.Issue, card *trelloWrapper.Card, cardURL string) error {
+	actions, err := card.GetComments()
+	if err != nil {
+		return errors.Wrapf(err, "Error getting comments for card \"%s\"", card.GetId())
+	}
+
+	// trello returns newest comments first
+	for idx := len(actions) - 1; idx >= 0; idx-- {
+		action := actions[idx]
+		if action.Data == nil ||
+			action.Date.Before(c.commentsSince) ||
+			trelloWrapper.IsSyncedComment(action.Data.Text) {
+			continue
+		}
+		mirrored, err := c.storage.IsMirroredComment(action.ID)
+		if err != nil {
+			return err
+		}
+		if mirrored {
+			continue
+		}
+
+		author := "unknown"
+		if action.MemberCreator != nil {
+			author = action.MemberCreator.FullName
+		}
+		fmt.Printf("\tMirroring trello comment %s to %s\n", action.ID, issue.URL)
+
+		body := syncer.GenerateTrelloComment(action.ID, author, cardURL, action.Data.Text)
+		commentId, err := c.addComment(issue, body)
+		if err != nil {
+			return err
+		}
+		if err := c.storage.SaveMirroredComment(&storage.TrelloComment{
+			TrelloActionId:  action.ID,
+			IssueId:         issue.IssueId,
+			GitHubCommentId: commentId,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cardSyncer) addComment(issue *storage.Issue, body string) (string, error) {
+	if plan := c.trello.Plan(); plan != nil {
+		plan.Record(trelloWrapper.Operation{
+			Type:     trelloWrapper.COMMENT_GITHUB_ISSUE,
+			CardName: issue.Title,
+			Text:     body,
+		})
+		return "", nil
+	}
+	return c.github.AddComment(issue.IssueId, body)
+}
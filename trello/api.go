@@ -0,0 +1,232 @@
+/* Rate limit aware HTTP layer shared by all wrapper requests */
+
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxRetries  = 5
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+// Error is a non 2xx response from trello. It satisfies the error interfaces
+// checked by IsRateLimit, IsNotFound and IsPermissionDenied in the vendored client.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("HTTP %s failure on %s: %d %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+func (e *Error) IsRateLimit() bool        { return e.StatusCode == http.StatusTooManyRequests }
+func (e *Error) IsNotFound() bool         { return e.StatusCode == http.StatusNotFound }
+func (e *Error) IsPermissionDenied() bool { return e.StatusCode == http.StatusUnauthorized }
+
+// IsRateLimit reports whether err, or the error it wraps, is a trello rate limit response
+func IsRateLimit(err error) bool {
+	return trello.IsRateLimit(errors.Cause(err))
+}
+
+// IsNotFound reports whether err, or the error it wraps, is a trello not found response
+func IsNotFound(err error) bool {
+	return trello.IsNotFound(errors.Cause(err))
+}
+
+// IsPermissionDenied reports whether err, or the error it wraps, is a trello unauthorized response
+func IsPermissionDenied(err error) bool {
+	return trello.IsPermissionDenied(errors.Cause(err))
+}
+
+// api sends requests to trello, honouring its rate limits. Rate limited
+// requests are always retried, other failures only for idempotent methods.
+type api struct {
+	client   *http.Client
+	baseURL  string
+	key      string
+	token    string
+	throttle func()
+	backoff  func(attempt int) time.Duration
+
+	mu         sync.Mutex
+	pauseUntil time.Time // set once trello reports the token's budget is spent
+}
+
+// newAPI sends requests with httpClient to baseURL, the vendored client's
+// defaults are used when they are unset
+func newAPI(client *trello.Client, httpClient *http.Client, baseURL string) *api {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if len(baseURL) == 0 {
+		baseURL = client.BaseURL
+	}
+	return &api{
+		client:   httpClient,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		key:      client.Key,
+		token:    client.Token,
+		throttle: client.Throttle,
+		backoff:  backoff,
+	}
+}
+
+func (a *api) do(method, path string, params, data map[string]string, target interface{}) error {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	query.Set("key", a.key)
+	query.Set("token", a.token)
+
+	form := url.Values{}
+	for k, v := range data {
+		form.Set(k, v)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s", a.baseURL, path)
+	if method != http.MethodGet {
+		fmt.Printf("%s URL: %s\n", method, endpoint)
+	}
+
+	for attempt := 0; ; attempt++ {
+		a.wait()
+
+		wait, err := a.send(method, endpoint, query, form, target)
+		if err == nil {
+			return nil
+		}
+		delay, retry := a.retryDelay(method, err, wait, attempt)
+		if !retry {
+			return err
+		}
+		fmt.Printf("\t[WARNING] %s, retrying in %s\n", err, delay)
+		time.Sleep(delay)
+	}
+}
+
+// send makes a single attempt, returning the delay the server asked for on failure
+func (a *api) send(method, endpoint string, query, form url.Values, target interface{}) (time.Duration, error) {
+	req, err := http.NewRequest(method, endpoint+"?"+query.Encode(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid %s request %s", method, endpoint)
+	}
+	if len(form) > 0 {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrapf(err, "HTTP %s failure on %s", method, endpoint)
+	}
+	defer resp.Body.Close()
+	a.observe(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return retryAfter(resp.Header), &Error{
+			Method:     method,
+			URL:        endpoint,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	if target != nil {
+		if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+			return 0, errors.Wrapf(err, "JSON decode failed on %s", endpoint)
+		}
+	}
+	return 0, nil
+}
+
+// retryDelay decides whether a failed attempt is retried and after how long
+func (a *api) retryDelay(method string, err error, wait time.Duration, attempt int) (time.Duration, bool) {
+	if attempt >= maxRetries {
+		return 0, false
+	}
+
+	idempotent := method != http.MethodPost
+	switch e := err.(type) {
+	case *Error:
+		switch {
+		case e.IsRateLimit():
+			if wait > 0 {
+				return wait, true
+			}
+		case e.StatusCode >= 500 && idempotent:
+		default:
+			return 0, false
+		}
+	default:
+		// transport failures, the request may or may not have been applied
+		if !idempotent {
+			return 0, false
+		}
+	}
+	return a.backoff(attempt), true
+}
+
+// backoff doubles with every attempt, with up to half of it added as jitter
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff << uint(attempt)
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// wait blocks until the token has budget left and the throttle allows a request
+func (a *api) wait() {
+	a.mu.Lock()
+	pause := time.Until(a.pauseUntil)
+	a.mu.Unlock()
+	if pause > 0 {
+		fmt.Printf("\tTrello rate limit reached, pausing for %s\n", pause)
+		time.Sleep(pause)
+	}
+	a.throttle()
+}
+
+// observe pauses further requests once trello reports no remaining budget
+// for the token in the current interval
+func (a *api) observe(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Api-Token-Remaining"))
+	if err != nil || remaining > 0 {
+		return
+	}
+	interval, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Api-Token-Interval-Ms"))
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.pauseUntil = time.Now().Add(time.Duration(interval) * time.Millisecond)
+	a.mu.Unlock()
+}
+
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,156 @@
+package trello
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newTestAPI sends requests to server without throttling or backing off
+func newTestAPI(server *httptest.Server) *api {
+	return &api{
+		client:   server.Client(),
+		baseURL:  server.URL,
+		key:      "key",
+		token:    "token",
+		throttle: func() {},
+		backoff:  func(int) time.Duration { return time.Millisecond },
+	}
+}
+
+// failingServer answers the first failures requests with status and the rest
+// with an empty JSON object, counting the requests it receives
+func failingServer(failures int32, status int, header http.Header) (*httptest.Server, *int32) {
+	requests := new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, http.StatusText(status))
+			return
+		}
+		fmt.Fprint(w, `{"id": "card"}`)
+	}))
+	return server, requests
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	server, requests := failingServer(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+	defer server.Close()
+
+	target := &struct{ ID string }{}
+	start := time.Now()
+	// rate limited requests are retried even when they aren't idempotent
+	if err := newTestAPI(server).do(http.MethodPost, "cards", nil, map[string]string{"name": "card"}, target); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %d", *requests)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected to wait for Retry-After, retried after %s", elapsed)
+	}
+	if target.ID != "card" {
+		t.Errorf("expected the retried response to be decoded, got %+v", target)
+	}
+}
+
+func TestServerErrorRetries(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		retried  bool
+		requests int32
+	}{
+		{http.MethodGet, true, 3},
+		{http.MethodPut, true, 3},
+		{http.MethodDelete, true, 3},
+		{http.MethodPost, false, 1},
+	} {
+		t.Run(test.method, func(t *testing.T) {
+			server, requests := failingServer(2, http.StatusServiceUnavailable, nil)
+			defer server.Close()
+
+			err := newTestAPI(server).do(test.method, "cards/card", nil, nil, nil)
+			if test.retried && err != nil {
+				t.Errorf("expected the request to succeed after retrying, got %s", err)
+			}
+			if !test.retried {
+				e, ok := errors.Cause(err).(*Error)
+				if !ok || e.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("expected a 503 error, got %v", err)
+				}
+			}
+			if *requests != test.requests {
+				t.Errorf("expected %d requests, got %d", test.requests, *requests)
+			}
+		})
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	server, requests := failingServer(maxRetries+10, http.StatusInternalServerError, nil)
+	defer server.Close()
+
+	if err := newTestAPI(server).do(http.MethodGet, "cards/card", nil, nil, nil); err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+	if *requests != maxRetries+1 {
+		t.Errorf("expected %d requests, got %d", maxRetries+1, *requests)
+	}
+}
+
+func TestErrorPredicates(t *testing.T) {
+	for _, test := range []struct {
+		status           int
+		rateLimit        bool
+		notFound         bool
+		permissionDenied bool
+	}{
+		{status: http.StatusTooManyRequests, rateLimit: true},
+		{status: http.StatusNotFound, notFound: true},
+		{status: http.StatusUnauthorized, permissionDenied: true},
+		{status: http.StatusBadRequest},
+	} {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			server, _ := failingServer(maxRetries+10, test.status, nil)
+			defer server.Close()
+
+			err := newTestAPI(server).do(http.MethodGet, "cards/card", nil, nil, nil)
+			err = errors.Wrap(err, "wrapped")
+			if IsRateLimit(err) != test.rateLimit {
+				t.Errorf("IsRateLimit: expected %t", test.rateLimit)
+			}
+			if IsNotFound(err) != test.notFound {
+				t.Errorf("IsNotFound: expected %t", test.notFound)
+			}
+			if IsPermissionDenied(err) != test.permissionDenied {
+				t.Errorf("IsPermissionDenied: expected %t", test.permissionDenied)
+			}
+		})
+	}
+}
+
+func TestRequestCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("key") != "key" || query.Get("token") != "token" || query.Get("fields") != "name" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if r.URL.Path != "/cards/card" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	if err := newTestAPI(server).do(http.MethodGet, "cards/card", map[string]string{"fields": "name"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+/* Creating the lists and labels the configuration refers to */
+
+package trello
+
+import (
+	"fmt"
+
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+// placeholderListPrefix marks list IDs handed out for lists a dry run would create
+const placeholderListPrefix = "dry-run-list-"
+
+// InitBoard adds the lists and labels missing from the board, lists are added
+// in order at the bottom of the board
+func (c *Client) InitBoard(lists []string, labels []Label) error {
+	for _, name := range lists {
+		if _, ok := c.listIDMap[name]; ok {
+			continue
+		}
+		if err := c.createList(name); err != nil {
+			return err
+		}
+	}
+
+	for _, label := range labels {
+		if c.findLabel(label) != nil {
+			continue
+		}
+		if _, err := c.createLabel(label.Name, label.Color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) createList(name string) error {
+	fmt.Printf("\tCreating trello list \"%s\"\n", name)
+	if plan := c.plan; plan != nil {
+		plan.Record(Operation{
+			Type: CREATE_LIST,
+			List: name,
+		})
+		c.listIDMap[name] = placeholderListPrefix + name
+		return nil
+	}
+
+	list := &trello.List{}
+	data := map[string]string{
+		"name":    name,
+		"idBoard": c.board.ID,
+		"pos":     "bottom",
+	}
+	if err := c.Post("lists", data, list); err != nil {
+		return errors.Wrapf(err, "Unable to create list \"%s\"", name)
+	}
+	c.listIDMap[name] = list.ID
+	return nil
+}
@@ -2,6 +2,8 @@ package trello
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/luccacabra/github-to-trello/storage"
 	"github.com/luccacabra/trello"
@@ -27,6 +29,18 @@ func (c *Client) NewCard(storageCard *storage.Card) *Card {
  */
 func (c *Card) Create() error {
 	fmt.Printf("\tCreating new trello card on list %s\n", c.storageCard.ListId)
+	if plan := c.client.plan; plan != nil {
+		c.storageCard.TrelloCardId = plan.newCardId()
+		plan.Record(Operation{
+			Type:     CREATE_CARD,
+			CardId:   c.storageCard.TrelloCardId,
+			CardName: c.storageCard.Title,
+			List:     c.client.listName(c.storageCard.ListId),
+			Labels:   c.client.labelNames(c.storageCard.LabelIds),
+		})
+		return nil
+	}
+
 	path := "cards"
 	data := map[string]string{
 		"name":     c.storageCard.Title,
@@ -35,6 +49,9 @@ func (c *Card) Create() error {
 		"idLabels": c.storageCard.LabelIds,
 		"idList":   c.storageCard.ListId,
 	}
+	if len(c.storageCard.Due) > 0 {
+		data["due"] = c.storageCard.Due
+	}
 
 	trelloCard := &trello.Card{}
 	if err := c.client.Post(path, data, trelloCard); err != nil {
@@ -47,6 +64,11 @@ func (c *Card) Create() error {
 }
 
 func (c *Card) Update(args map[string]string) error {
+	if c.client.plan != nil {
+		c.planCardUpdate(args)
+		return nil
+	}
+
 	path := fmt.Sprintf("cards/%s", c.storageCard.TrelloCardId)
 	return c.client.Put(path, args, c)
 }
@@ -55,20 +77,63 @@ func (c *Card) GetId() string {
 	return c.storageCard.TrelloCardId
 }
 
+// Fetch reads the card's current list, archive state and URL from trello
+func (c *Card) Fetch() (*trello.Card, error) {
+	// cards created during a dry run don't exist yet
+	if isPlaceholderCardId(c.storageCard.TrelloCardId) {
+		return &trello.Card{IDList: c.storageCard.ListId}, nil
+	}
+
+	trelloCard := &trello.Card{}
+	path := fmt.Sprintf("cards/%s", c.storageCard.TrelloCardId)
+	if err := c.client.Get(
+		path,
+		map[string]string{
+			"fields": "idList,closed,shortUrl",
+		},
+		trelloCard,
+	); err != nil {
+		return nil, errors.Wrapf(err, "Error fetching card %s", c.storageCard.TrelloCardId)
+	}
+	return trelloCard, nil
+}
+
 /*
 *
 *  CARD - COMMENTS
 *
  */
-func (c *Card) CreateComment(comment string) error {
+// CreateComment comments on the card, returning the comment's action ID
+func (c *Card) CreateComment(comment string) (string, error) {
+	if plan := c.client.plan; plan != nil {
+		plan.Record(Operation{
+			Type:     CREATE_COMMENT,
+			CardId:   c.storageCard.TrelloCardId,
+			CardName: c.storageCard.Title,
+			Text:     comment,
+		})
+		return "", nil
+	}
+
+	action := &trello.Action{}
 	path := fmt.Sprintf("cards/%s/actions/comments", c.storageCard.TrelloCardId)
-	if err := c.client.Post(path, map[string]string{"text": comment}, nil); err != nil {
-		return errors.Wrapf(err, "Error commenting on card %s", c.storageCard.TrelloCardId)
+	if err := c.client.Post(path, map[string]string{"text": comment}, action); err != nil {
+		return "", errors.Wrapf(err, "Error commenting on card %s", c.storageCard.TrelloCardId)
 	}
-	return nil
+	return action.ID, nil
 }
 
 func (c *Card) DeleteComment(commentActionID string) error {
+	if plan := c.client.plan; plan != nil {
+		plan.Record(Operation{
+			Type:      DELETE_COMMENT,
+			CardId:    c.storageCard.TrelloCardId,
+			CardName:  c.storageCard.Title,
+			CommentId: commentActionID,
+		})
+		return nil
+	}
+
 	path := fmt.Sprintf("cards/%s/actions/%s/comments", c.storageCard.TrelloCardId, commentActionID)
 	if err := c.client.Delete(path, map[string]string{}, nil); err != nil {
 		return errors.Wrapf(err, "Error deleting comment '%s' from card '%s'", commentActionID, c.storageCard.TrelloCardId)
@@ -77,6 +142,11 @@ func (c *Card) DeleteComment(commentActionID string) error {
 }
 
 func (c *Card) GetComments() (trello.ActionCollection, error) {
+	// cards created during a dry run don't exist yet
+	if isPlaceholderCardId(c.storageCard.TrelloCardId) {
+		return trello.ActionCollection{}, nil
+	}
+
 	actionCollection := &trello.ActionCollection{}
 	path := fmt.Sprintf("cards/%s/actions", c.storageCard.TrelloCardId)
 	if err := c.client.Get(
@@ -92,6 +162,17 @@ func (c *Card) GetComments() (trello.ActionCollection, error) {
 }
 
 func (c *Card) UpdateComment(comment, commentActionID string) error {
+	if plan := c.client.plan; plan != nil {
+		plan.Record(Operation{
+			Type:      UPDATE_COMMENT,
+			CardId:    c.storageCard.TrelloCardId,
+			CardName:  c.storageCard.Title,
+			CommentId: commentActionID,
+			Text:      comment,
+		})
+		return nil
+	}
+
 	path := fmt.Sprintf("cards/%s/actions/%s/comments", c.storageCard.TrelloCardId, commentActionID)
 	if err := c.client.Put(
 		path,
@@ -104,99 +185,158 @@ func (c *Card) UpdateComment(comment, commentActionID string) error {
 	return nil
 }
 
-func (c *Card) SyncComments(comments []*storage.Comment) (bool, error) {
-	fmt.Printf("\t\tSyncing comments for trello card %s on list %s\n", c.storageCard.Title, c.storageCard.LabelIds)
-	// Find existing comments for this card
-	cardCommentActions, err := c.GetComments()
-	if err != nil {
-		return false, errors.Wrapf(err, "Error getting comments for card \"%s\"", c.storageCard.TrelloCardId)
+// SyncComments brings the card's synced comments in line with comments, the
+// issue's current GitHub comments. mapped holds the comment actions synced to
+// the card so far. Only comments that were added, edited or deleted on GitHub
+// are touched, comments made directly on the card are left alone. The updated
+// mapping is returned.
+func (c *Card) SyncComments(mapped, comments []*storage.Comment) ([]*storage.Comment, error) {
+	fmt.Printf("\t\tSyncing %d comments for trello card %s on list %s\n", len(comments), c.storageCard.Title, c.storageCard.ListId)
+
+	// cards synced before comments were mapped are matched by their text
+	if len(mapped) == 0 {
+		cardCommentActions, err := c.GetComments()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting comments for card \"%s\"", c.storageCard.TrelloCardId)
+		}
+		mapped = unmappedComments(syncedComments(cardCommentActions))
 	}
 
-	newActivity, err := c.syncComments(cardCommentActions, comments)
+	mappings, newActivity, err := c.syncComments(mapped, comments)
 	if err != nil {
-		return false, errors.Wrapf(err, "Error syncing comments for card \"%s\"", c.storageCard.TrelloCardId)
+		return nil, errors.Wrapf(err, "Error syncing comments for card \"%s\"", c.storageCard.TrelloCardId)
 	}
-	return newActivity, nil
+
+	// re-apply labels if card was updated
+	if newActivity {
+		if err := c.markNewAcivity(); err != nil {
+			return nil, errors.Wrapf(err, "Error marking new activity on card \"%s\"", c.storageCard.TrelloCardId)
+		}
+	}
+	return mappings, nil
 }
 
-func (c *Card) syncComments(oldComments []*trello.Action, newComments []*storage.Comment) (bool, error) {
-	fmt.Printf(
-		"\t\tSyncing %d comments for trello card %s on list %s\n",
-		len(newComments),
-		c.storageCard.Title,
-		c.storageCard.ListId,
-	)
-	// trello posts comments in reverse order, so flip 'em here
-	newComments = reverse(newComments)
+func (c *Card) syncComments(mapped, comments []*storage.Comment) ([]*storage.Comment, bool, error) {
+	byGitHubId := map[string]*storage.Comment{}
+	unidentified := []*storage.Comment{}
+	for _, m := range mapped {
+		if len(m.GitHubId) > 0 {
+			byGitHubId[m.GitHubId] = m
+		} else {
+			unidentified = append(unidentified, m)
+		}
+	}
 
-	idx := 0
+	mappings := []*storage.Comment{}
 	newActivity := false
-	//oldComments := reverse(oldCommentsReversed) // trello returns comments in reverse order
-
-	for _, oldComment := range oldComments {
-		// check for case: comments deleted from GH Issue
-		if idx >= len(newComments) {
-			fmt.Printf("\t\t\tDeleting stale comment %s...\n", oldComment.Data.Text[:10])
-			if err := c.DeleteComment(oldComment.ID); err != nil {
-				return false,
-					errors.Wrapf(err,
-						"Error deleting stale comment \"%s\" from card \"%s\"", oldComment.ID, c.storageCard.TrelloCardId)
+	for _, comment := range comments {
+		m, ok := byGitHubId[comment.GitHubId]
+		if ok {
+			delete(byGitHubId, comment.GitHubId)
+		} else if m, unidentified = matchComment(unidentified, comment.Body); m == nil {
+			// check for case: new comment added to GH Issue
+			fmt.Printf("\t\t\tAdding new comment %s...\n", summarize(comment.Body))
+			actionId, err := c.CreateComment(comment.Body)
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "Error creating new comment to card \"%s\"", c.storageCard.TrelloCardId)
 			}
+			mappings = append(mappings, cardComment(comment, actionId, comment.Body))
 			newActivity = true
-		} else { // check for case: GH Issue comment text changed
-			if oldComment.Data.Text != newComments[idx].Body {
-				fmt.Printf("\t\t\tUpdating stale comment %s...\n", oldComment.Data.Text[:10])
-				err := c.UpdateComment(newComments[idx].Body, oldComment.ID)
-				if err != nil {
-					switch errors.Cause(err).(type) {
-					case *trello.ErrorURLLengthExceeded:
-						fmt.Printf(
-							"[WARNING] Unable to update comment for card \"%s\""+
-								"- request URL exceeded maximum length allowed.\n",
-							c.storageCard.Title,
-						)
-						return false, nil
-					default:
-						return false, errors.Wrapf(err, "Error updating comment \"%s\" to card \"%s\"", oldComment.ID, c.storageCard.TrelloCardId)
-					}
-				}
-				newActivity = true
-			}
+			continue
 		}
-		idx += 1
-	}
 
-	// check for case: new comments added to GH Issue
-	if idx < len(newComments) {
-		for i := idx; i < len(newComments); i++ {
-			fmt.Printf("\t\t\tAdding new comment %s...\n", newComments[i].Body[:10])
-			if err := c.CreateComment(newComments[i].Body); err != nil {
-				return false, errors.Wrapf(err, "Error creating new comment to card \"%s\"", c.storageCard.TrelloCardId)
+		// check for case: GH Issue comment text changed
+		if m.Body != comment.Body {
+			fmt.Printf("\t\t\tUpdating stale comment %s...\n", summarize(m.Body))
+			if err := c.UpdateComment(comment.Body, m.TrelloActionId); err != nil {
+				return nil, false, errors.Wrapf(err, "Error updating comment \"%s\" to card \"%s\"", m.TrelloActionId, c.storageCard.TrelloCardId)
 			}
 			newActivity = true
 		}
+		mappings = append(mappings, cardComment(comment, m.TrelloActionId, comment.Body))
 	}
 
-	// re-apply labels if card was updated
-	if newActivity {
-		c.markNewAcivity()
+	// check for case: comments deleted from GH Issue
+	stale := unidentified
+	for _, m := range byGitHubId {
+		stale = append(stale, m)
 	}
-	return newActivity, nil
+	for _, m := range stale {
+		if len(m.TrelloActionId) == 0 {
+			continue
+		}
+		fmt.Printf("\t\t\tDeleting stale comment %s...\n", summarize(m.Body))
+		if err := c.DeleteComment(m.TrelloActionId); err != nil {
+			return nil, false, errors.Wrapf(err, "Error deleting stale comment \"%s\" from card \"%s\"", m.TrelloActionId, c.storageCard.TrelloCardId)
+		}
+		newActivity = true
+	}
+
+	return mappings, newActivity, nil
 }
 
+// markNewAcivity bumps the card's activity by writing back the labels it
+// currently has, labels added on the board are kept
 func (c *Card) markNewAcivity() error {
-	if err := c.Update(map[string]string{
-		"idLabels": c.storageCard.LabelIds,
-	}); err != nil {
+	if c.client.plan != nil {
+		return nil
+	}
+	labelIds, err := c.fetchLabelIds()
+	if err != nil {
 		return err
 	}
-	return nil
+	return c.Update(map[string]string{
+		"idLabels": strings.Join(labelIds, ","),
+	})
+}
+
+// syncedCommentLink ends every comment mirrored from GitHub, see syncer.Renderer
+var syncedCommentLink = regexp.MustCompile(`\[View on GitHub\]\([^)]+\)\s*$`)
+
+// IsSyncedComment reports whether a card comment was mirrored from GitHub
+func IsSyncedComment(text string) bool {
+	return syncedCommentLink.MatchString(text)
+}
+
+func syncedComments(actions []*trello.Action) []*trello.Action {
+	synced := []*trello.Action{}
+	for _, action := range actions {
+		if action.Data != nil && IsSyncedComment(action.Data.Text) {
+			synced = append(synced, action)
+		}
+	}
+	return synced
+}
+
+// unmappedComments converts synced comment actions, oldest first
+func unmappedComments(actions []*trello.Action) []*storage.Comment {
+	comments := make([]*storage.Comment, len(actions))
+	for idx, action := range actions {
+		comments[len(actions)-1-idx] = &storage.Comment{
+			TrelloActionId: action.ID,
+			Body:           action.Data.Text,
+		}
+	}
+	return comments
+}
+
+// matchComment removes and returns the first comment with the given body
+func matchComment(comments []*storage.Comment, body string) (*storage.Comment, []*storage.Comment) {
+	for idx, comment := range comments {
+		if comment.Body == body {
+			return comment, append(comments[:idx:idx], comments[idx+1:]...)
+		}
+	}
+	return nil, comments
 }
 
-func reverse(a []*storage.Comment) []*storage.Comment {
-	for i := len(a)/2 - 1; i >= 0; i-- {
-		opp := len(a) - 1 - i
-		a[i], a[opp] = a[opp], a[i]
+// cardComment maps a GitHub comment to the trello comment action it was synced to
+func cardComment(comment *storage.Comment, trelloActionId, body string) *storage.Comment {
+	return &storage.Comment{
+		IssueId:        comment.IssueId,
+		GitHubId:       comment.GitHubId,
+		UpdatedAt:      comment.UpdatedAt,
+		TrelloActionId: trelloActionId,
+		Body:           body,
 	}
-	return a
 }
@@ -3,30 +3,45 @@
 package trello
 
 import (
-	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 
-	"github.com/levigross/grequests"
 	"github.com/luccacabra/trello"
-	"github.com/pkg/errors"
 )
 
 type ClientConfig struct {
-	BoardName     string
-	LabelCardName string
-	LabelMap      map[string]string
+	BoardName string
+	// Members maps GitHub assignees and reviewers to board members
+	Members MemberConfig
+
+	// BaseURL and HTTPClient override where and how requests are sent,
+	// defaulting to the trello API and http.DefaultClient
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// DryRun records card changes in a plan instead of sending them to Trello
+	DryRun bool
 }
 
 type Client struct {
 	config ClientConfig
 
 	client *trello.Client
+	api    *api
 	board  *trello.Board
 
-	labelIDMap map[string]string // label Name -> label ID
-	listIDMap  map[string]string // list Name  -> *trello.List
+	labels    []*trello.Label   // labels of the board
+	members   []*trello.Member  // members of the board, unless members aren't mapped
+	listIDMap map[string]string // list Name  -> *trello.List
 
+	// cardIndex holds the open identified cards by GitHub node ID, nil until
+	// a card is first looked up and after a refresh
+	cardIndex   map[string][]*trello.Card
+	cardIndexMu sync.Mutex
+
+	plan *Plan // nil unless running dry
 }
 
 func NewClient(key, token string, config ClientConfig) *Client {
@@ -34,94 +49,65 @@ func NewClient(key, token string, config ClientConfig) *Client {
 		client: trello.NewClient(key, token),
 		config: config,
 	}
+	c.api = newAPI(c.client, config.HTTPClient, config.BaseURL)
 
-	c.labelIDMap = map[string]string{}
 	c.listIDMap = map[string]string{}
 
-	c.loadResources(config)
+	if config.DryRun {
+		c.plan = &Plan{}
+	}
+
+	if err := c.loadResources(config); err != nil {
+		log.Fatalf("Unable to initialize trello connection: %s", err)
+	}
 
 	return c
 }
 
-func (c *Client) parseResponse(resp *http.Response, target interface{}) error {
-	if target != nil {
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return errors.New(fmt.Sprintf("Unexpected HTTP response code %s", resp.StatusCode))
+// Plan returns the changes recorded during a dry run, nil otherwise
+func (c *Client) Plan() *Plan {
+	return c.plan
+}
+
+func (c *Client) listName(listId string) string {
+	for name, id := range c.listIDMap {
+		if id == listId {
+			return name
 		}
+	}
+	return listId
+}
 
-		decoder := json.NewDecoder(resp.Body)
-		if err := decoder.Decode(target); err != nil {
-			return errors.Wrap(err, "JSON decode failed")
+func (c *Client) labelNames(labelIds string) []string {
+	names := []string{}
+	for _, labelId := range strings.Split(labelIds, ",") {
+		if len(labelId) == 0 {
+			continue
+		}
+		name := labelId
+		for _, label := range c.labels {
+			if label.ID == labelId {
+				name = labelDisplayName(label)
+				break
+			}
 		}
+		names = append(names, name)
 	}
-	return nil
+	return names
 }
 
 func (c *Client) Delete(path string, params map[string]string, target interface{}) error {
-	params["key"] = c.client.Key
-	params["token"] = c.client.Token
-
-	return c.client.Delete(path, params, target)
+	return c.api.do(http.MethodDelete, path, params, nil, target)
 }
 
 func (c *Client) Get(path string, params map[string]string, target interface{}) error {
-	params["key"] = c.client.Key
-	params["token"] = c.client.Token
-
-	return c.client.Get(path, params, target)
+	return c.api.do(http.MethodGet, path, params, nil, target)
 }
 
 func (c *Client) Post(path string, data map[string]string, target interface{}) error {
-	// Trello prohibits more than 10 seconds/second per token
-	c.client.Throttle()
-
-	params := map[string]string{
-		"key":   c.client.Key,
-		"token": c.client.Token,
-	}
-
-	url := fmt.Sprintf("%s/%s", c.client.BaseURL, path)
-
-	fmt.Printf("POST URL: %s\n", url)
-
-	resp, err := grequests.Post(
-		url,
-		&grequests.RequestOptions{
-			Data:   data,
-			Params: params,
-		},
-	)
-	if err != nil {
-		return errors.Wrapf(err, "HTTP POST failure on %s", url)
-	}
-
-	return c.parseResponse(resp.RawResponse, target)
+	return c.api.do(http.MethodPost, path, nil, data, target)
 }
 
 func (c *Client) Put(path string, data map[string]string, target interface{}) error {
-	// Trello prohibits more than 10 seconds/second per token
-	c.client.Throttle()
-
-	params := map[string]string{
-		"key":   c.client.Key,
-		"token": c.client.Token,
-	}
-
-	url := fmt.Sprintf("%s/%s", c.client.BaseURL, path)
-
-	fmt.Printf("PUT URL: %s\n", url)
-
-	resp, err := grequests.Put(
-		url,
-		&grequests.RequestOptions{
-			Data:   data,
-			Params: params,
-		},
-	)
-	if err != nil {
-		return errors.Wrapf(err, "HTTP PUT failure on %s", url)
-	}
-
-	return c.parseResponse(resp.RawResponse, target)
+	return c.api.do(http.MethodPut, path, nil, data, target)
 }
@@ -8,17 +8,24 @@ const (
 	CLOSE
 )
 
+// Label refers to a board label by name, colour or both
+type Label struct {
+	Name  string
+	Color string
+}
+
 type Actions struct {
 	Create struct {
 		Lists  []string
-		Labels []string
+		Labels []Label
 	}
 	Update struct {
 		Lists  []string
-		Labels []string
+		Labels []Label
 	}
 	Close struct {
-		Lists  []string
-		Labels []string
+		Lists   []string
+		Labels  []Label
+		Archive bool
 	}
 }
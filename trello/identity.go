@@ -0,0 +1,197 @@
+/* Machine readable issue <-> card identity */
+
+package trello
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/luccacabra/github-to-trello/storage"
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+// identityPrefix names the attachment linking a card to its GitHub issue or
+// pull request, the node ID follows the prefix
+const identityPrefix = "github-to-trello:"
+
+// Filters of the cards listed by BoardCards
+const (
+	OpenCards = "open"
+	// AllCards includes archived cards
+	AllCards = "all"
+)
+
+// legacyLink ends the description of cards created before identities existed
+var legacyLink = regexp.MustCompile(`\[View on GitHub\]\((https://github\.com/[^)]+)\)\s*$`)
+
+// CardIdentity returns the GitHub node ID from the card's identity
+// attachment, or failing that the GitHub URL its description links to
+func CardIdentity(card *trello.Card) (gitHubId, gitHubURL string) {
+	for _, attachment := range card.Attachments {
+		if strings.HasPrefix(attachment.Name, identityPrefix) {
+			return strings.TrimPrefix(attachment.Name, identityPrefix), attachment.URL
+		}
+	}
+	if match := legacyLink.FindStringSubmatch(card.Desc); match != nil {
+		return "", match[1]
+	}
+	return "", ""
+}
+
+func cardLabelIds(card *trello.Card) string {
+	labelIds := make([]string, len(card.Labels))
+	for idx, label := range card.Labels {
+		labelIds[idx] = label.ID
+	}
+	return strings.Join(labelIds, ",")
+}
+
+// BoardCards lists the cards on the board matching filter along with their
+// attachments
+func (c *Client) BoardCards(filter string) ([]*trello.Card, error) {
+	path := fmt.Sprintf("boards/%s/cards", c.board.ID)
+	params := map[string]string{
+		"filter":            filter,
+		"attachments":       "true",
+		"attachment_fields": "name,url",
+	}
+
+	cards := []*trello.Card{}
+	// trello caps the cards returned at once, older ones are paged with before
+	for {
+		page := []*trello.Card{}
+		if err := c.Get(path, params, &page); err != nil {
+			return nil, errors.Wrapf(err, "Unable to list cards on board %s", c.board.Name)
+		}
+		if len(page) == 0 {
+			return cards, nil
+		}
+		cards = append(cards, page...)
+		params["before"] = trello.EarliestCardID(cards)
+	}
+}
+
+// StorageCard converts a board card to a card instance for gitHubId, the
+// issue ID is left for the caller to resolve
+func StorageCard(card *trello.Card, gitHubId string) *storage.Card {
+	return &storage.Card{
+		GitHubId:     gitHubId,
+		Title:        card.Name,
+		Text:         card.Desc,
+		TrelloCardId: card.ID,
+		ListId:       card.IDList,
+		LabelIds:     cardLabelIds(card),
+	}
+}
+
+// findCardByIdentity returns the ID of the open card on the list of
+// storageCard carrying its identity, empty if there is none. Cards are looked
+// up in the identity index, cards missing from it are searched for.
+func (c *Client) findCardByIdentity(storageCard *storage.Card, issueURL string) (string, error) {
+	index, err := c.identityIndex()
+	if err != nil {
+		return "", err
+	}
+	for _, card := range index[storageCard.GitHubId] {
+		if card.IDList == storageCard.ListId {
+			return card.ID, nil
+		}
+	}
+	return c.searchCard(storageCard, issueURL)
+}
+
+// identityIndex groups the open cards on the board by the GitHub node ID of
+// their identity. The board is listed once and the index kept until Refresh.
+func (c *Client) identityIndex() (map[string][]*trello.Card, error) {
+	c.cardIndexMu.Lock()
+	defer c.cardIndexMu.Unlock()
+	if c.cardIndex != nil {
+		return c.cardIndex, nil
+	}
+
+	cards, err := c.BoardCards(OpenCards)
+	if err != nil {
+		return nil, err
+	}
+	c.cardIndex = map[string][]*trello.Card{}
+	for _, card := range cards {
+		if gitHubId, _ := CardIdentity(card); len(gitHubId) > 0 {
+			c.cardIndex[gitHubId] = append(c.cardIndex[gitHubId], card)
+		}
+	}
+	return c.cardIndex, nil
+}
+
+// searchCard searches the board for the card of storageCard by its title,
+// catching cards added since the identity index was loaded. Only a card on
+// its list carrying its identity, or without one linking to issueURL, matches.
+func (c *Client) searchCard(storageCard *storage.Card, issueURL string) (string, error) {
+	result := &trello.SearchResult{}
+	if err := c.Get(
+		"search",
+		map[string]string{
+			"query":            fmt.Sprintf("board:%s \"%s\"", c.board.ID, strings.Replace(storageCard.Title, `"`, "", -1)),
+			"modelTypes":       "cards",
+			"card_attachments": "true",
+		},
+		result,
+	); err != nil {
+		return "", errors.Wrapf(err, "Unable to search for card \"%s\"", storageCard.Title)
+	}
+
+	for _, card := range result.Cards {
+		if card.IDList != storageCard.ListId {
+			continue
+		}
+		gitHubId, gitHubURL := CardIdentity(card)
+		if gitHubId == storageCard.GitHubId || (len(gitHubId) == 0 && gitHubURL == issueURL) {
+			return card.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// FindIdentifiedCards returns a card instance for every card on the board
+// carrying an identity, archived ones included, issue IDs are left for the
+// caller to resolve. The IDs of cards without one are returned too.
+func (c *Client) FindIdentifiedCards() ([]*storage.Card, []string, error) {
+	cards, err := c.BoardCards(AllCards)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identified := []*storage.Card{}
+	unidentified := []string{}
+	for _, card := range cards {
+		gitHubId, _ := CardIdentity(card)
+		if len(gitHubId) == 0 {
+			unidentified = append(unidentified, card.ID)
+			continue
+		}
+		identified = append(identified, StorageCard(card, gitHubId))
+	}
+	return identified, unidentified, nil
+}
+
+// AttachIdentity links the card to its GitHub issue or pull request
+func (c *Card) AttachIdentity(issueURL string) error {
+	// nothing is attached during a dry run
+	if c.client.plan != nil {
+		return nil
+	}
+
+	path := fmt.Sprintf("cards/%s/attachments", c.storageCard.TrelloCardId)
+	if err := c.client.Post(
+		path,
+		map[string]string{
+			"name": identityPrefix + c.storageCard.GitHubId,
+			"url":  issueURL,
+		},
+		nil,
+	); err != nil {
+		return errors.Wrapf(err, "Error attaching identity to card %s", c.storageCard.TrelloCardId)
+	}
+	return nil
+}
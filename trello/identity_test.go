@@ -0,0 +1,89 @@
+package trello
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luccacabra/github-to-trello/storage"
+)
+
+// identityServer serves a board with one identified card, a card only found
+// by searching and counts the board listings and searches
+type identityServer struct {
+	t        *testing.T
+	listings int
+	searches int
+}
+
+func (s *identityServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch {
+	case r.URL.Path == "/search" && query.Get("modelTypes") == "boards":
+		fmt.Fprint(w, `{"boards": [{"id": "board", "name": "Board"}]}`)
+	case r.URL.Path == "/search":
+		s.searches++
+		if query.Get("query") != `board:board "Added"` {
+			s.t.Errorf("unexpected search %q", query.Get("query"))
+		}
+		fmt.Fprint(w, `{"cards": [
+			{"id": "other-list", "idList": "done", "attachments": [{"name": "github-to-trello:added"}]},
+			{"id": "added", "idList": "todo", "attachments": [{"name": "github-to-trello:added"}]}
+		]}`)
+	case r.URL.Path == "/boards/board/lists":
+		fmt.Fprint(w, `[{"id": "todo", "name": "To Do"}, {"id": "done", "name": "Done"}]`)
+	case r.URL.Path == "/boards/board/labels":
+		fmt.Fprint(w, `[]`)
+	case r.URL.Path == "/boards/board/cards":
+		if len(query.Get("before")) > 0 {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		s.listings++
+		fmt.Fprint(w, `[{"id": "5a0000020000000000000000", "idList": "todo", "attachments": [{"name": "github-to-trello:indexed"}]}]`)
+	default:
+		s.t.Errorf("unexpected request %s", r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func TestFindCardByIdentity(t *testing.T) {
+	fake := &identityServer{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := NewClient("key", "token", ClientConfig{BoardName: "Board", BaseURL: server.URL})
+	for _, test := range []struct {
+		card     *storage.Card
+		expected string
+	}{
+		{&storage.Card{GitHubId: "indexed", ListId: "todo", Title: "Indexed"}, "5a0000020000000000000000"},
+		{&storage.Card{GitHubId: "added", ListId: "todo", Title: "Added"}, "added"},
+		{&storage.Card{GitHubId: "indexed", ListId: "todo", Title: "Indexed"}, "5a0000020000000000000000"},
+	} {
+		trelloCardId, err := c.findCardByIdentity(test.card, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trelloCardId != test.expected {
+			t.Errorf("expected card %q for %s, got %q", test.expected, test.card.GitHubId, trelloCardId)
+		}
+	}
+	if fake.listings != 1 {
+		t.Errorf("expected the board to be listed once, got %d", fake.listings)
+	}
+	if fake.searches != 1 {
+		t.Errorf("expected a single search for the card missing from the index, got %d", fake.searches)
+	}
+
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.findCardByIdentity(&storage.Card{GitHubId: "indexed", ListId: "todo"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if fake.listings != 2 {
+		t.Errorf("expected the board to be listed again after a refresh, got %d", fake.listings)
+	}
+}
@@ -1,50 +1,39 @@
 package trello
 
 import (
-	"log"
+	"fmt"
 
 	"github.com/luccacabra/trello"
 	"github.com/pkg/errors"
 )
 
 func (c *Client) loadBoard(boardName string) error {
-	boards, err := c.client.SearchBoards(boardName, trello.Defaults())
-	if err != nil {
+	result := &trello.SearchResult{}
+	if err := c.Get("search", map[string]string{"query": boardName, "modelTypes": "boards"}, result); err != nil {
 		return errors.Wrapf(err, "Unable to get board ID for board %s", boardName)
 	}
-	if len(boards) > 0 {
-		for _, board := range boards {
-			if board.Name == boardName {
-				c.board = board
-				return nil
-			}
+	for _, board := range result.Boards {
+		if board.Name == boardName {
+			c.board = board
+			return nil
 		}
 	}
 	return errors.New("Unable to find board ID for board \"" + boardName + "\"")
 }
 
-func (c *Client) loadLabelMap() error {
-	cards, err := c.client.SearchCards(c.config.LabelCardName, trello.Defaults())
+func (c *Client) loadLabels() error {
+	labels, err := c.getBoardLabels()
 	if err != nil {
-		return errors.Wrapf(err, "Unable to get label map for card id \"%s\"", c.config.LabelCardName)
-	}
-
-	for _, card := range cards {
-		if card.Name == c.config.LabelCardName {
-			for _, label := range card.Labels {
-				if len(label.Name) > 0 {
-					c.labelIDMap[label.Name] = label.ID
-				}
-			}
-			return nil
-		}
+		return err
 	}
-	return errors.New("Could not find labels for card \"" + c.config.LabelCardName + "\"")
+	c.labels = labels
+	return nil
 }
 
 func (c *Client) loadListMap() error {
-	lists, err := c.board.GetLists(trello.Defaults())
-	if err != nil {
+	lists := []*trello.List{}
+	path := fmt.Sprintf("boards/%s/lists", c.board.ID)
+	if err := c.Get(path, map[string]string{}, &lists); err != nil {
 		return errors.Wrapf(err, "Could not get lists for board \"%s\"", c.board.Name)
 	}
 
@@ -54,21 +43,36 @@ func (c *Client) loadListMap() error {
 	return nil
 }
 
-func (c *Client) loadResources(config ClientConfig) {
+func (c *Client) loadResources(config ClientConfig) error {
 	if err := c.loadBoard(config.BoardName); err != nil {
-		log.Fatalf("Unable to initalize trello connection: %s", err)
+		return err
 	}
+	return c.loadMaps(config)
+}
 
+func (c *Client) loadMaps(config ClientConfig) error {
 	if err := c.loadListMap(); err != nil {
-		log.Fatalf("Unable to initialize trello connection: %s", err)
+		return err
+	}
+	if err := c.loadLabels(); err != nil {
+		return err
 	}
+	return c.loadMembers()
+}
 
-	if len(config.LabelMap) == 0 {
-		if len(config.LabelCardName) == 0 {
-			log.Fatal("Must specify either 'trello_label_map' or 'trello_label_card_name'")
-		}
-		if err := c.loadLabelMap(); err != nil {
-			log.Fatalf("Unable to initialize trello connection: %s", err)
-		}
+// Refresh reloads the board's lists, labels and members, keeping the previous
+// ones if the reload fails. The identity index is reloaded on the next lookup.
+func (c *Client) Refresh() error {
+	labels, members, listIDMap := c.labels, c.members, c.listIDMap
+	c.listIDMap = map[string]string{}
+
+	if err := c.loadMaps(c.config); err != nil {
+		c.labels, c.members, c.listIDMap = labels, members, listIDMap
+		return errors.Wrap(err, "Unable to refresh trello lists and labels")
 	}
+
+	c.cardIndexMu.Lock()
+	c.cardIndex = nil
+	c.cardIndexMu.Unlock()
+	return nil
 }
@@ -0,0 +1,69 @@
+package trello
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// boardServer serves a board named "Board" with two lists, a label and cards
+// spread over two pages
+func boardServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			if r.URL.Query().Get("modelTypes") != "boards" {
+				t.Errorf("unexpected search %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"boards": [{"id": "other", "name": "Board 2"}, {"id": "board", "name": "Board"}]}`)
+		case "/boards/board/lists":
+			fmt.Fprint(w, `[{"id": "todo", "name": "To Do"}, {"id": "done", "name": "Done"}]`)
+		case "/boards/board/labels":
+			fmt.Fprint(w, `[{"id": "bug", "name": "Bug", "color": "red"}, {"id": "green", "name": "", "color": "green"}]`)
+		case "/boards/board/cards":
+			switch r.URL.Query().Get("before") {
+			case "":
+				fmt.Fprint(w, `[{"id": "5a0000020000000000000000", "name": "new"}]`)
+			case "5a0000020000000000000000":
+				fmt.Fprint(w, `[{"id": "5a0000010000000000000000", "name": "old"}]`)
+			default:
+				fmt.Fprint(w, `[]`)
+			}
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestNewClientLoadsBoard(t *testing.T) {
+	server := boardServer(t)
+	defer server.Close()
+
+	c := NewClient("key", "token", ClientConfig{BoardName: "Board", BaseURL: server.URL})
+	if c.board.ID != "board" {
+		t.Errorf("expected board \"board\", got %q", c.board.ID)
+	}
+	if c.GetListIdForName("Done") != "done" {
+		t.Errorf("expected list \"Done\" to be loaded, got %v", c.listIDMap)
+	}
+	labelIds := c.GetLabelIds([]Label{{Name: "Bug"}, {Color: "green"}, {Name: "Missing"}})
+	if len(labelIds) != 2 || labelIds[0] != "bug" || labelIds[1] != "green" {
+		t.Errorf("unexpected label IDs %v", labelIds)
+	}
+}
+
+func TestBoardCardsPages(t *testing.T) {
+	server := boardServer(t)
+	defer server.Close()
+
+	c := NewClient("key", "token", ClientConfig{BoardName: "Board", BaseURL: server.URL})
+	cards, err := c.BoardCards(OpenCards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 2 || cards[0].Name != "new" || cards[1].Name != "old" {
+		t.Errorf("expected both pages of cards, got %d", len(cards))
+	}
+}
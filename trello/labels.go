@@ -0,0 +1,248 @@
+/* Trello board labels */
+
+package trello
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+// placeholderLabelPrefix marks label IDs handed out for labels a dry run would create
+const placeholderLabelPrefix = "dry-run-label-"
+
+// GitHubLabel is a trello label mapped from a GitHub label, Color is the
+// GitHub hex colour
+type GitHubLabel struct {
+	Name  string
+	Color string
+}
+
+// labelColors are trello's label colours as shown on the board
+var labelColors = []struct {
+	name    string
+	r, g, b int64
+}{
+	{"green", 0x61, 0xbd, 0x4f},
+	{"yellow", 0xf2, 0xd6, 0x00},
+	{"orange", 0xff, 0x9f, 0x1a},
+	{"red", 0xeb, 0x5a, 0x46},
+	{"purple", 0xc3, 0x77, 0xe0},
+	{"blue", 0x00, 0x79, 0xbf},
+	{"sky", 0x00, 0xc2, 0xe0},
+	{"lime", 0x51, 0xe8, 0x98},
+	{"pink", 0xff, 0x78, 0xcb},
+	{"black", 0x34, 0x45, 0x63},
+}
+
+// closestColor returns the trello colour nearest to a GitHub hex colour, no
+// colour if it can't be parsed
+func closestColor(hex string) string {
+	rgb, err := strconv.ParseInt(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return ""
+	}
+	r, g, b := rgb>>16, rgb>>8&0xff, rgb&0xff
+
+	closest, distance := "", int64(-1)
+	for _, color := range labelColors {
+		dr, dg, db := r-color.r, g-color.g, b-color.b
+		if d := dr*dr + dg*dg + db*db; distance < 0 || d < distance {
+			closest, distance = color.name, d
+		}
+	}
+	return closest
+}
+
+// GitHubLabelIds returns the IDs of the trello labels for labels. Labels that
+// are not on the board are created when create is set, and returned as missing
+// otherwise.
+func (c *Client) GitHubLabelIds(labels []GitHubLabel, create bool) ([]string, []string, error) {
+	labelIds := []string{}
+	missing := []string{}
+	for _, label := range labels {
+		if boardLabel := c.findLabel(Label{Name: label.Name}); boardLabel != nil {
+			labelIds = append(labelIds, boardLabel.ID)
+			continue
+		}
+		if !create {
+			missing = append(missing, label.Name)
+			continue
+		}
+		boardLabel, err := c.createLabel(label.Name, closestColor(label.Color))
+		if err != nil {
+			return nil, nil, err
+		}
+		labelIds = append(labelIds, boardLabel.ID)
+	}
+	return labelIds, missing, nil
+}
+
+// findLabel returns the board label label refers to, nil if there is none.
+// Labels given only by colour prefer the board's unnamed label of that colour.
+func (c *Client) findLabel(label Label) *trello.Label {
+	var found *trello.Label
+	for _, boardLabel := range c.labels {
+		if len(label.Name) > 0 && boardLabel.Name != label.Name {
+			continue
+		}
+		if len(label.Color) > 0 && boardLabel.Color != label.Color {
+			continue
+		}
+		if len(label.Name) > 0 || len(boardLabel.Name) == 0 {
+			return boardLabel
+		}
+		if found == nil {
+			found = boardLabel
+		}
+	}
+	return found
+}
+
+// labelDisplayName names a board label, unnamed labels go by their colour
+func labelDisplayName(label *trello.Label) string {
+	if len(label.Name) == 0 {
+		return label.Color
+	}
+	return label.Name
+}
+
+func (c *Client) getBoardLabels() ([]*trello.Label, error) {
+	labels := []*trello.Label{}
+	path := fmt.Sprintf("boards/%s/labels", c.board.ID)
+	if err := c.Get(path, map[string]string{"fields": "name,color", "limit": "1000"}, &labels); err != nil {
+		return nil, errors.Wrapf(err, "Unable to get labels of board %s", c.board.Name)
+	}
+	return labels, nil
+}
+
+// createLabel adds a label to the board, dry runs record the operation and
+// hand out a placeholder
+func (c *Client) createLabel(name, color string) (*trello.Label, error) {
+	fmt.Printf("\tCreating trello label \"%s\" (%s)\n", name, color)
+	label := &trello.Label{Name: name, Color: color}
+	if plan := c.plan; plan != nil {
+		plan.Record(Operation{
+			Type:   CREATE_LABEL,
+			Labels: []string{name},
+			Text:   color,
+		})
+		label.ID = placeholderLabelPrefix + name
+		c.labels = append(c.labels, label)
+		return label, nil
+	}
+
+	data := map[string]string{
+		"name":    name,
+		"idBoard": c.board.ID,
+	}
+	if len(color) > 0 {
+		data["color"] = color
+	}
+	if err := c.Post("labels", data, label); err != nil {
+		return nil, errors.Wrapf(err, "Unable to create label \"%s\"", name)
+	}
+	c.labels = append(c.labels, label)
+	return label, nil
+}
+
+// UpdateLabels adds and removes labels of the card through the card's label
+// endpoints, so labels added on the board are kept. Labels already on the card
+// aren't added again and labels missing from it aren't removed.
+func (c *Card) UpdateLabels(add, remove []string) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	current, err := c.fetchLabelIds()
+	if err != nil {
+		return err
+	}
+	onCard := map[string]bool{}
+	for _, labelId := range current {
+		onCard[labelId] = true
+	}
+	added, removed := []string{}, []string{}
+	for _, labelId := range add {
+		if !onCard[labelId] {
+			added = append(added, labelId)
+			onCard[labelId] = true
+		}
+	}
+	for _, labelId := range remove {
+		if onCard[labelId] {
+			removed = append(removed, labelId)
+			onCard[labelId] = false
+		}
+	}
+
+	if plan := c.client.plan; plan != nil {
+		if len(added) > 0 || len(removed) > 0 {
+			plan.Record(Operation{
+				Type:          LABEL_CARD,
+				CardId:        c.storageCard.TrelloCardId,
+				CardName:      c.storageCard.Title,
+				Labels:        c.client.labelNames(strings.Join(added, ",")),
+				RemovedLabels: c.client.labelNames(strings.Join(removed, ",")),
+			})
+		}
+		c.storageCard.LabelIds = changeIds(strings.Join(current, ","), add, remove)
+		return nil
+	}
+
+	for _, labelId := range added {
+		path := fmt.Sprintf("cards/%s/idLabels", c.storageCard.TrelloCardId)
+		if err := c.client.Post(path, map[string]string{"value": labelId}, nil); err != nil {
+			return errors.Wrapf(err, "Error adding label %s to card %s", labelId, c.storageCard.TrelloCardId)
+		}
+	}
+	for _, labelId := range removed {
+		path := fmt.Sprintf("cards/%s/idLabels/%s", c.storageCard.TrelloCardId, labelId)
+		if err := c.client.Delete(path, map[string]string{}, nil); err != nil {
+			return errors.Wrapf(err, "Error removing label %s from card %s", labelId, c.storageCard.TrelloCardId)
+		}
+	}
+	c.storageCard.LabelIds = changeIds(strings.Join(current, ","), add, remove)
+	return nil
+}
+
+// fetchLabelIds reads the IDs of the labels currently on the card
+func (c *Card) fetchLabelIds() ([]string, error) {
+	// cards created during a dry run don't exist yet
+	if isPlaceholderCardId(c.storageCard.TrelloCardId) {
+		if len(c.storageCard.LabelIds) == 0 {
+			return nil, nil
+		}
+		return strings.Split(c.storageCard.LabelIds, ","), nil
+	}
+
+	trelloCard := &struct {
+		IDLabels []string `json:"idLabels"`
+	}{}
+	path := fmt.Sprintf("cards/%s", c.storageCard.TrelloCardId)
+	if err := c.client.Get(path, map[string]string{"fields": "idLabels"}, trelloCard); err != nil {
+		return nil, errors.Wrapf(err, "Error fetching labels of card %s", c.storageCard.TrelloCardId)
+	}
+	return trelloCard.IDLabels, nil
+}
+
+// changeIds adds and removes IDs of a comma separated set of IDs
+func changeIds(ids string, add, remove []string) string {
+	removed := map[string]bool{}
+	for _, id := range remove {
+		removed[id] = true
+	}
+	changed := []string{}
+	seen := map[string]bool{}
+	for _, id := range append(strings.Split(ids, ","), add...) {
+		if len(id) == 0 || seen[id] || removed[id] {
+			continue
+		}
+		seen[id] = true
+		changed = append(changed, id)
+	}
+	return strings.Join(changed, ",")
+}
@@ -0,0 +1,61 @@
+package trello
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luccacabra/github-to-trello/storage"
+)
+
+// labelServer serves a board with two labels and a card labelled "Bug"
+func labelServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet:
+			t.Errorf("unexpected %s %s during a dry run", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		case r.URL.Path == "/search":
+			fmt.Fprint(w, `{"boards": [{"id": "board", "name": "Board"}]}`)
+		case r.URL.Path == "/boards/board/lists":
+			fmt.Fprint(w, `[{"id": "todo", "name": "To Do"}]`)
+		case r.URL.Path == "/boards/board/labels":
+			fmt.Fprint(w, `[{"id": "bug", "name": "Bug"}, {"id": "feature", "name": "Feature"}]`)
+		case r.URL.Path == "/cards/card":
+			fmt.Fprint(w, `{"id": "card", "idLabels": ["bug"]}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestDryRunUpdateLabels(t *testing.T) {
+	server := labelServer(t)
+	defer server.Close()
+
+	c := NewClient("key", "token", ClientConfig{BoardName: "Board", BaseURL: server.URL, DryRun: true})
+	storageCard := &storage.Card{TrelloCardId: "card", Title: "Card"}
+	card := c.NewCard(storageCard)
+
+	if err := card.UpdateLabels([]string{"bug", "feature"}, []string{"stale"}); err != nil {
+		t.Fatal(err)
+	}
+	ops := c.Plan().Operations
+	if len(ops) != 1 || strings.Join(ops[0].Labels, ",") != "Feature" || len(ops[0].RemovedLabels) != 0 {
+		t.Fatalf("expected only the missing label to be planned, got %+v", ops)
+	}
+	if storageCard.LabelIds != "bug,feature" {
+		t.Errorf("expected the card to record both labels, got %q", storageCard.LabelIds)
+	}
+
+	c.Plan().Reset()
+	if err := card.UpdateLabels([]string{"bug"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ops := c.Plan().Operations; len(ops) != 0 {
+		t.Errorf("expected no change for labels already on the card, got %+v", ops)
+	}
+}
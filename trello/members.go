@@ -0,0 +1,146 @@
+/* Trello board members for GitHub users */
+
+package trello
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+// MemberConfig maps GitHub users to members of the board
+type MemberConfig struct {
+	// Map maps GitHub logins to trello usernames
+	Map map[string]string
+	// MatchNames falls back to the board member whose full name is the GitHub
+	// user's name
+	MatchNames bool `mapstructure:"match_names"`
+}
+
+func (c MemberConfig) enabled() bool {
+	return len(c.Map) > 0 || c.MatchNames
+}
+
+// GitHubUser is a GitHub user to find the board member for
+type GitHubUser struct {
+	Login string
+	Name  string
+}
+
+// GitHubMemberIds returns the IDs of the board members for users, users
+// without one are returned as missing
+func (c *Client) GitHubMemberIds(users []GitHubUser) ([]string, []string) {
+	memberIds := []string{}
+	missing := []string{}
+	if !c.config.Members.enabled() {
+		return memberIds, missing
+	}
+
+	for _, user := range users {
+		member := c.findMember(user)
+		if member == nil {
+			missing = append(missing, user.Login)
+			continue
+		}
+		memberIds = append(memberIds, member.ID)
+	}
+	return memberIds, missing
+}
+
+func (c *Client) findMember(user GitHubUser) *trello.Member {
+	// viper lower cases map keys
+	username, mapped := c.config.Members.Map[strings.ToLower(user.Login)]
+	username = strings.TrimPrefix(username, "@")
+	for _, member := range c.members {
+		if mapped && strings.EqualFold(member.Username, username) {
+			return member
+		}
+	}
+	if mapped || !c.config.Members.MatchNames || len(user.Name) == 0 {
+		return nil
+	}
+	for _, member := range c.members {
+		if strings.EqualFold(member.FullName, user.Name) {
+			return member
+		}
+	}
+	return nil
+}
+
+func (c *Client) memberNames(memberIds []string) []string {
+	names := make([]string, len(memberIds))
+	for idx, memberId := range memberIds {
+		names[idx] = memberId
+		for _, member := range c.members {
+			if member.ID == memberId {
+				names[idx] = member.Username
+				break
+			}
+		}
+	}
+	return names
+}
+
+func (c *Client) loadMembers() error {
+	if !c.config.Members.enabled() {
+		return nil
+	}
+
+	members := []*trello.Member{}
+	path := fmt.Sprintf("boards/%s/members", c.board.ID)
+	if err := c.Get(path, map[string]string{"fields": "username,fullName"}, &members); err != nil {
+		return errors.Wrapf(err, "Unable to get members of board %s", c.board.Name)
+	}
+	c.members = members
+	return nil
+}
+
+// UpdateMembers adds and removes members of the card. Members already on the
+// card aren't added again and members missing from it aren't removed.
+func (c *Card) UpdateMembers(add, remove []string) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	if plan := c.client.plan; plan != nil {
+		plan.Record(Operation{
+			Type:           MEMBER_CARD,
+			CardId:         c.storageCard.TrelloCardId,
+			CardName:       c.storageCard.Title,
+			Members:        c.client.memberNames(add),
+			RemovedMembers: c.client.memberNames(remove),
+		})
+		return nil
+	}
+
+	trelloCard := &trello.Card{}
+	path := fmt.Sprintf("cards/%s", c.storageCard.TrelloCardId)
+	if err := c.client.Get(path, map[string]string{"fields": "idMembers"}, trelloCard); err != nil {
+		return errors.Wrapf(err, "Error fetching members of card %s", c.storageCard.TrelloCardId)
+	}
+	current := map[string]bool{}
+	for _, memberId := range trelloCard.IDMembers {
+		current[memberId] = true
+	}
+
+	for _, memberId := range add {
+		if current[memberId] {
+			continue
+		}
+		path := fmt.Sprintf("cards/%s/idMembers", c.storageCard.TrelloCardId)
+		if err := c.client.Post(path, map[string]string{"value": memberId}, nil); err != nil {
+			return errors.Wrapf(err, "Error adding member %s to card %s", memberId, c.storageCard.TrelloCardId)
+		}
+	}
+	for _, memberId := range remove {
+		if !current[memberId] {
+			continue
+		}
+		path := fmt.Sprintf("cards/%s/idMembers/%s", c.storageCard.TrelloCardId, memberId)
+		if err := c.client.Delete(path, map[string]string{}, nil); err != nil {
+			return errors.Wrapf(err, "Error removing member %s from card %s", memberId, c.storageCard.TrelloCardId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type OperationType string
+
+const (
+	CREATE_CARD    OperationType = "create_card"
+	UPDATE_CARD                  = "update_card"
+	MOVE_CARD                    = "move_card"
+	LABEL_CARD                   = "label_card"
+	ARCHIVE_CARD                 = "archive_card"
+	UNARCHIVE_CARD               = "unarchive_card"
+	CREATE_COMMENT               = "create_comment"
+	UPDATE_COMMENT               = "update_comment"
+	DELETE_COMMENT               = "delete_comment"
+	CREATE_LABEL                 = "create_label"
+	CREATE_LIST                  = "create_list"
+	MEMBER_CARD                  = "member_card"
+
+	CLOSE_GITHUB_ISSUE   = "close_github_issue"
+	REOPEN_GITHUB_ISSUE  = "reopen_github_issue"
+	COMMENT_GITHUB_ISSUE = "comment_github_issue"
+)
+
+// placeholderCardPrefix marks card IDs handed out for cards a dry run would create
+const placeholderCardPrefix = "dry-run-"
+
+// Operation is a single Trello change a sync would have made
+type Operation struct {
+	Type      OperationType `json:"type"`
+	CardId    string        `json:"card_id,omitempty"`
+	CardName  string        `json:"card_name"`
+	List      string        `json:"list,omitempty"`
+	Labels    []string      `json:"labels,omitempty"`
+	Fields    []string      `json:"fields,omitempty"`
+	CommentId string        `json:"comment_id,omitempty"`
+	Text      string        `json:"text,omitempty"`
+
+	// Labels and Members are added to the card, RemovedLabels and
+	// RemovedMembers removed from it
+	RemovedLabels  []string `json:"removed_labels,omitempty"`
+	Members        []string `json:"members,omitempty"`
+	RemovedMembers []string `json:"removed_members,omitempty"`
+}
+
+// Plan records the changes of a dry run instead of executing them. Besides
+// Trello changes it holds GitHub changes made by the two way sync.
+type Plan struct {
+	Operations []Operation `json:"operations"`
+
+	cards int
+}
+
+// Reset discards the recorded operations, placeholder card IDs stay unique
+func (p *Plan) Reset() {
+	p.Operations = nil
+}
+
+func (p *Plan) Record(op Operation) {
+	p.Operations = append(p.Operations, op)
+}
+
+func (p *Plan) newCardId() string {
+	p.cards++
+	return fmt.Sprintf("%s%d", placeholderCardPrefix, p.cards)
+}
+
+func isPlaceholderCardId(cardId string) bool {
+	return strings.HasPrefix(cardId, placeholderCardPrefix)
+}
+
+// Print writes a human readable summary of the plan
+func (p *Plan) Print(w io.Writer) {
+	if len(p.Operations) == 0 {
+		fmt.Fprintln(w, "No Trello changes planned")
+		return
+	}
+
+	fmt.Fprintf(w, "%d Trello changes planned:\n", len(p.Operations))
+	for _, op := range p.Operations {
+		fmt.Fprintf(w, "%s\n", op)
+	}
+}
+
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+func (o Operation) String() string {
+	switch o.Type {
+	case CREATE_CARD:
+		return fmt.Sprintf("+ create card \"%s\" on list \"%s\" with labels [%s]", o.CardName, o.List, strings.Join(o.Labels, ", "))
+	case UPDATE_CARD:
+		return fmt.Sprintf("~ update %s of card \"%s\"", strings.Join(o.Fields, ", "), o.CardName)
+	case MOVE_CARD:
+		return fmt.Sprintf("> move card \"%s\" to list \"%s\"", o.CardName, o.List)
+	case LABEL_CARD:
+		return fmt.Sprintf("~ change labels of card \"%s\": %s", o.CardName, changes(o.Labels, o.RemovedLabels))
+	case MEMBER_CARD:
+		return fmt.Sprintf("~ change members of card \"%s\": %s", o.CardName, changes(o.Members, o.RemovedMembers))
+	case ARCHIVE_CARD:
+		return fmt.Sprintf("- archive card \"%s\"", o.CardName)
+	case UNARCHIVE_CARD:
+		return fmt.Sprintf("+ unarchive card \"%s\"", o.CardName)
+	case CREATE_COMMENT:
+		return fmt.Sprintf("+ add comment to card \"%s\": %s", o.CardName, summarize(o.Text))
+	case UPDATE_COMMENT:
+		return fmt.Sprintf("~ edit comment %s on card \"%s\": %s", o.CommentId, o.CardName, summarize(o.Text))
+	case DELETE_COMMENT:
+		return fmt.Sprintf("- delete comment %s from card \"%s\"", o.CommentId, o.CardName)
+	case CREATE_LABEL:
+		return fmt.Sprintf("+ create label \"%s\" (%s)", strings.Join(o.Labels, ", "), o.Text)
+	case CREATE_LIST:
+		return fmt.Sprintf("+ create list \"%s\"", o.List)
+	case CLOSE_GITHUB_ISSUE:
+		return fmt.Sprintf("- close GitHub issue for card \"%s\"", o.CardName)
+	case REOPEN_GITHUB_ISSUE:
+		return fmt.Sprintf("+ reopen GitHub issue for card \"%s\"", o.CardName)
+	case COMMENT_GITHUB_ISSUE:
+		return fmt.Sprintf("+ add GitHub comment from card \"%s\": %s", o.CardName, summarize(o.Text))
+	}
+	return fmt.Sprintf("? %s card \"%s\"", o.Type, o.CardName)
+}
+
+// changes lists added and removed names as +added -removed
+func changes(added, removed []string) string {
+	changed := []string{}
+	for _, name := range added {
+		changed = append(changed, "+"+name)
+	}
+	for _, name := range removed {
+		changed = append(changed, "-"+name)
+	}
+	return strings.Join(changed, " ")
+}
+
+func summarize(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > 60 {
+		return text[:57] + "..."
+	}
+	return text
+}
+
+// planCardUpdate splits card update arguments into the operations they represent
+func (c *Card) planCardUpdate(args map[string]string) {
+	plan := c.client.plan
+	op := Operation{
+		CardId:   c.storageCard.TrelloCardId,
+		CardName: c.storageCard.Title,
+	}
+
+	fields := []string{}
+	for _, field := range []string{"name", "desc", "due"} {
+		if _, ok := args[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) > 0 {
+		update := op
+		update.Type = UPDATE_CARD
+		update.Fields = fields
+		plan.Record(update)
+	}
+	if listId, ok := args["idList"]; ok {
+		move := op
+		move.Type = MOVE_CARD
+		move.List = c.client.listName(listId)
+		plan.Record(move)
+	}
+	switch args["closed"] {
+	case "true":
+		archive := op
+		archive.Type = ARCHIVE_CARD
+		plan.Record(archive)
+	case "false":
+		unarchive := op
+		unarchive.Type = UNARCHIVE_CARD
+		plan.Record(unarchive)
+	}
+}
@@ -2,54 +2,61 @@ package trello
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/luccacabra/github-to-trello/storage"
-	"github.com/luccacabra/trello"
 	"github.com/pkg/errors"
 )
 
-func (c *Client) CreateNewCard(storageCard *storage.Card) (*Card, error) {
+// CreateNewCard creates the card for storageCard unless it is already known,
+// either by its trello card ID or by a card on its list carrying its identity
+func (c *Client) CreateNewCard(storageCard *storage.Card, issueURL string) (*Card, error) {
 	fmt.Printf("\tCreating new trello card on list %s\n", storageCard.ListId)
 
-	card, err := c.getCard(storageCard)
+	card, err := c.getCard(storageCard, issueURL)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to create new trello card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
 	}
 
 	// If card already existed (from a failed run) update it with the correct info
 	if len(card.storageCard.TrelloCardId) > 0 {
-		if err = card.Update(map[string]string{
-			"desc":     storageCard.Text,
-			"idLabels": storageCard.LabelIds,
-		}); err != nil {
+		args := map[string]string{
+			"desc": storageCard.Text,
+		}
+		if len(storageCard.Due) > 0 {
+			args["due"] = storageCard.Due
+		}
+		if err = card.Update(args); err != nil {
 			return nil, errors.Wrapf(err, "Failed to create new trello card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
 		}
+		if len(storageCard.LabelIds) > 0 {
+			if err = card.UpdateLabels(strings.Split(storageCard.LabelIds, ","), nil); err != nil {
+				return nil, errors.Wrapf(err, "Failed to create new trello card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
+			}
+		}
 
 	} else {
 		// Otherwise, create the card
 		if err := card.Create(); err != nil {
 			return nil, errors.Wrapf(err, "Failed to create new trello card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
 		}
+		if err := card.AttachIdentity(issueURL); err != nil {
+			return nil, errors.Wrapf(err, "Failed to create new trello card \"%s\" on list %s", storageCard.Title, storageCard.ListId)
+		}
 	}
 
 	return card, nil
 }
-func (c *Client) SyncCommentsForNewCard(storageCard *storage.Card, comments []*storage.Comment) error {
-	fmt.Printf("\t\tSyncing %d comments for card %s on list %s\n", len(comments), storageCard.Title, storageCard.ListId)
-	card := c.NewCard(storageCard)
-	_, err := card.SyncComments(comments)
-	if err != nil {
-		return errors.Wrap(err, "Error syncing comments for new card")
-	}
-	return nil
-}
 
-func (c *Client) GetLabelIdsForNames(labelNames []string) []string {
-	labelIds := make([]string, len(labelNames))
-	for idx, labelName := range labelNames {
-		labelIds[idx] = c.labelIDMap[labelName]
+// GetLabelIds returns the IDs of the board labels labels refer to, labels not
+// on the board are left out
+func (c *Client) GetLabelIds(labels []Label) []string {
+	labelIds := []string{}
+	for _, label := range labels {
+		if boardLabel := c.findLabel(label); boardLabel != nil {
+			labelIds = append(labelIds, boardLabel.ID)
+		}
 	}
-
 	return labelIds
 }
 
@@ -57,23 +64,20 @@ func (c *Client) GetListIdForName(listName string) string {
 	return c.listIDMap[listName]
 }
 
-func (c *Client) getCard(storageCard *storage.Card) (*Card, error) {
-	trelloCards, err := c.client.SearchCards(fmt.Sprintf("board:%s \"%s\"", c.board.ID, storageCard.Title), trello.Defaults())
-	fmt.Printf("Found %d trello cards with name %s\n", len(trelloCards), storageCard.Title)
+func (c *Client) getCard(storageCard *storage.Card, issueURL string) (*Card, error) {
+	card := c.NewCard(storageCard)
+	// stored mapping wins, the board is only searched without one
+	if len(storageCard.TrelloCardId) > 0 {
+		return card, nil
+	}
+
+	trelloCardId, err := c.findCardByIdentity(storageCard, issueURL)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error looking up card \"%s\"", storageCard.Title)
 	}
-	for _, trelloCard := range trelloCards {
-		if trelloCard.IDList == storageCard.ListId {
-			storageCard.TrelloCardId = trelloCard.ID
-			return &Card{
-				client:      c,
-				storageCard: storageCard,
-			}, nil
-		}
+	if len(trelloCardId) > 0 {
+		fmt.Printf("Found existing trello card %s for %s\n", trelloCardId, storageCard.GitHubId)
+		storageCard.TrelloCardId = trelloCardId
 	}
-	return &Card{
-		client:      c,
-		storageCard: storageCard,
-	}, nil
+	return card, nil
 }
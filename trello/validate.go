@@ -0,0 +1,141 @@
+/* Checking the lists and labels the configuration refers to against the board */
+
+package trello
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator collects the lists and labels the configuration refers to that are
+// missing from the board
+type Validator struct {
+	client   *Client
+	problems []string
+}
+
+// Validator starts a validation pass against the loaded board
+func (c *Client) Validator() *Validator {
+	return &Validator{client: c}
+}
+
+// List checks the list name found at the config path
+func (v *Validator) List(path, name string) {
+	if _, ok := v.client.listIDMap[name]; ok {
+		return
+	}
+
+	names := []string{}
+	for listName := range v.client.listIDMap {
+		names = append(names, listName)
+	}
+	v.report(path, fmt.Sprintf("unknown list \"%s\"", name), suggest(name, names))
+}
+
+// Label checks the label found at the config path
+func (v *Validator) Label(path string, label Label) {
+	if v.client.findLabel(label) != nil {
+		return
+	}
+
+	switch named := v.client.findLabel(Label{Name: label.Name}); {
+	case len(label.Name) > 0 && named != nil:
+		v.report(path, fmt.Sprintf("label \"%s\" is %s, not %s", label.Name, named.Color, label.Color), "")
+	case len(label.Name) > 0:
+		names := []string{}
+		for _, boardLabel := range v.client.labels {
+			if len(boardLabel.Name) > 0 {
+				names = append(names, boardLabel.Name)
+			}
+		}
+		problem := fmt.Sprintf("unknown label \"%s\"", label.Name)
+		if len(label.Color) > 0 {
+			problem = fmt.Sprintf("unknown %s label \"%s\"", label.Color, label.Name)
+		}
+		v.report(path, problem, suggest(label.Name, names))
+	default:
+		colors := make([]string, len(labelColors))
+		for idx, color := range labelColors {
+			colors[idx] = color.name
+		}
+		if suggestion := suggest(label.Color, colors); suggestion != label.Color {
+			v.report(path, fmt.Sprintf("unknown label colour \"%s\"", label.Color), suggestion)
+			return
+		}
+		v.report(path, fmt.Sprintf("no %s label", label.Color), "")
+	}
+}
+
+// Err returns every problem found, nil if there are none
+func (v *Validator) Err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf(
+		"Config refers to lists and labels missing from board \"%s\" (run init-board to create them):\n\t%s",
+		v.client.board.Name,
+		strings.Join(v.problems, "\n\t"),
+	)
+}
+
+func (v *Validator) report(path, problem, suggestion string) {
+	if len(suggestion) > 0 {
+		problem += fmt.Sprintf(", did you mean \"%s\"?", suggestion)
+	}
+	v.problems = append(v.problems, fmt.Sprintf("%s: %s", path, problem))
+}
+
+// suggest returns the candidate closest to name by edit distance, ignoring
+// case. Nothing is suggested when even the closest one differs in more than a
+// third of name.
+func suggest(name string, candidates []string) string {
+	suggestion, distance := "", -1
+	for _, candidate := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < 0 || d < distance || (d == distance && candidate < suggestion) {
+			suggestion, distance = candidate, d
+		}
+	}
+
+	limit := utf8.RuneCountInString(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if distance < 0 || distance > limit {
+		return ""
+	}
+	return suggestion
+}
+
+// editDistance is the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, value := range values[1:] {
+		if value < m {
+			m = value
+		}
+	}
+	return m
+}
@@ -0,0 +1,40 @@
+package trello
+
+import (
+	"fmt"
+
+	"github.com/luccacabra/trello"
+	"github.com/pkg/errors"
+)
+
+// RegisterWebhook registers callbackURL for changes to the board, reusing an
+// existing webhook for the same URL
+func (c *Client) RegisterWebhook(callbackURL string) (*trello.Webhook, error) {
+	webhooks := []*trello.Webhook{}
+	path := fmt.Sprintf("tokens/%s/webhooks", c.client.Token)
+	if err := c.Get(path, map[string]string{}, &webhooks); err != nil {
+		return nil, errors.Wrap(err, "Unable to list trello webhooks")
+	}
+	for _, webhook := range webhooks {
+		if webhook.IDModel == c.board.ID && webhook.CallbackURL == callbackURL {
+			fmt.Printf("Using existing trello webhook %s\n", webhook.ID)
+			return webhook, nil
+		}
+	}
+
+	webhook := &trello.Webhook{
+		IDModel:     c.board.ID,
+		Description: fmt.Sprintf("github-to-trello: %s", c.board.Name),
+		CallbackURL: callbackURL,
+	}
+	data := map[string]string{
+		"idModel":     webhook.IDModel,
+		"description": webhook.Description,
+		"callbackURL": webhook.CallbackURL,
+	}
+	if err := c.Post("webhooks", data, webhook); err != nil {
+		return nil, errors.Wrapf(err, "Unable to register trello webhook for %s", callbackURL)
+	}
+	fmt.Printf("Registered trello webhook %s\n", webhook.ID)
+	return webhook, nil
+}
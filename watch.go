@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultSyncInterval    = 5 * time.Minute
+	defaultRefreshInterval = time.Hour
+)
+
+type watchConfig struct {
+	interval time.Duration
+	jitter   time.Duration
+	refresh  time.Duration
+}
+
+// loadWatchConfig resolves watch settings from flags, then config, then defaults
+func loadWatchConfig() watchConfig {
+	config := watchConfig{
+		interval: *watchInterval,
+		jitter:   *watchJitter,
+		refresh:  *watchRefresh,
+	}
+	if config.interval == 0 {
+		config.interval = viper.GetDuration("sync_interval")
+	}
+	if config.interval <= 0 {
+		config.interval = defaultSyncInterval
+	}
+	if config.jitter == 0 {
+		config.jitter = viper.GetDuration("sync_jitter")
+	}
+	if config.refresh == 0 {
+		config.refresh = viper.GetDuration("trello_refresh_interval")
+	}
+	if config.refresh <= 0 {
+		config.refresh = defaultRefreshInterval
+	}
+	return config
+}
+
+// watch syncs on an interval until SIGINT or SIGTERM is received. Failed syncs
+// are logged and retried on the next interval.
+func (a *app) watch(config watchConfig) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	fmt.Printf("Syncing every %s (jitter %s)\n", config.interval, config.jitter)
+	lastRefresh := time.Now()
+
+	for {
+		if time.Since(lastRefresh) >= config.refresh {
+			fmt.Println("Refreshing trello lists and labels")
+			if err := a.trello.Refresh(); err != nil {
+				log.Printf("[WARNING] %s", err)
+			} else {
+				lastRefresh = time.Now()
+			}
+		}
+
+		if err := a.sync(); err != nil {
+			log.Printf("[ERROR] Sync failed: %s", err)
+		}
+
+		wait := config.interval
+		if config.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(config.jitter)))
+		}
+		fmt.Printf("Next sync in %s\n", wait.Round(time.Second))
+
+		select {
+		case <-time.After(wait):
+		case sig := <-signals:
+			fmt.Printf("Received %s, shutting down\n", sig)
+			return
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/* Receiver for GitHub webhooks that triggers targeted syncs */
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/luccacabra/github-to-trello/syncer"
+	"github.com/pkg/errors"
+)
+
+// maxPayloadSize is the largest payload GitHub delivers
+const maxPayloadSize = 25 << 20
+
+const queueSize = 100
+
+type GitHubConfig struct {
+	Secret string
+
+	Issues       syncer.ItemSyncer
+	PullRequests syncer.ItemSyncer
+
+	// Synced, if set, is called after each queued item has been synced
+	Synced func()
+}
+
+type GitHubHandler struct {
+	secret []byte
+
+	issues       syncer.ItemSyncer
+	pullRequests syncer.ItemSyncer
+	synced       func()
+
+	// mu guards queue against sends once Close has closed it
+	mu     sync.RWMutex
+	closed bool
+	queue  chan item
+	done   chan struct{}
+}
+
+type item struct {
+	event    string
+	id       string
+	syncer   syncer.ItemSyncer
+	itemType string
+}
+
+// payload holds the parts of the supported events needed to find the affected item
+type payload struct {
+	Issue *struct {
+		NodeID      string           `json:"node_id"`
+		PullRequest *json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	PullRequest *struct {
+		NodeID string `json:"node_id"`
+	} `json:"pull_request"`
+}
+
+// NewGitHubHandler returns a handler for GitHub webhooks. Matching items are
+// synced one at a time in the background, call Close to stop.
+func NewGitHubHandler(config GitHubConfig) *GitHubHandler {
+	h := &GitHubHandler{
+		secret:       []byte(config.Secret),
+		issues:       config.Issues,
+		pullRequests: config.PullRequests,
+		synced:       config.Synced,
+		queue:        make(chan item, queueSize),
+		done:         make(chan struct{}),
+	}
+	go h.work()
+	return h
+}
+
+// Close stops accepting work and waits for queued syncs to finish
+func (h *GitHubHandler) Close() {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+	<-h.done
+}
+
+func (h *GitHubHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "unable to read payload", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validSignature(r.Header.Get("X-Hub-Signature-256"), body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	i, err := h.parse(event, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if i == nil {
+		fmt.Printf("Ignoring GitHub %s event\n", event)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case h.queue <- *i:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "sync queue full", http.StatusServiceUnavailable)
+	}
+}
+
+func (h *GitHubHandler) validSignature(signature string, body []byte) bool {
+	const prefix = "sha256="
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parse maps an event to the issue or pull request it affects, nil if unsupported
+func (h *GitHubHandler) parse(event string, body []byte) (*item, error) {
+	p := &payload{}
+	if err := json.Unmarshal(body, p); err != nil {
+		return nil, errors.Wrapf(err, "Unable to decode %s payload", event)
+	}
+
+	switch event {
+	case "issues", "issue_comment":
+		if p.Issue == nil {
+			return nil, errors.Errorf("%s payload has no issue", event)
+		}
+		// GitHub reports comments on pull requests as issue comments
+		if p.Issue.PullRequest != nil {
+			return &item{event, p.Issue.NodeID, h.pullRequests, "pull request"}, nil
+		}
+		return &item{event, p.Issue.NodeID, h.issues, "issue"}, nil
+	case "pull_request", "pull_request_review", "pull_request_review_comment":
+		if p.PullRequest == nil {
+			return nil, errors.Errorf("%s payload has no pull request", event)
+		}
+		return &item{event, p.PullRequest.NodeID, h.pullRequests, "pull request"}, nil
+	}
+	return nil, nil
+}
+
+func (h *GitHubHandler) work() {
+	defer close(h.done)
+	for i := range h.queue {
+		fmt.Printf("Syncing %s %s for GitHub %s event\n", i.itemType, i.id, i.event)
+		if err := i.syncer.SyncItem(i.id); err != nil {
+			log.Printf("[ERROR] Sync of %s %s failed: %s", i.itemType, i.id, err)
+		}
+		if h.synced != nil {
+			h.synced()
+		}
+	}
+}
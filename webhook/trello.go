@@ -0,0 +1,264 @@
+/* Receiver for trello board webhooks that publishes typed card events */
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type TrelloCard struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// TrelloEvent is a change made to a card on the board
+type TrelloEvent interface {
+	Card() TrelloCard
+}
+
+type CardMovedEvent struct {
+	TrelloCard
+	FromListId string
+	ToListId   string
+}
+
+type CardArchivedEvent struct {
+	TrelloCard
+	// Archived is false when the card was restored
+	Archived bool
+}
+
+type CardCommentedEvent struct {
+	TrelloCard
+	ActionId string
+	Author   string
+	Text     string
+}
+
+type CardLabeledEvent struct {
+	TrelloCard
+	LabelId   string
+	LabelName string
+	// Added is false when the label was removed
+	Added bool
+}
+
+func (c TrelloCard) Card() TrelloCard {
+	return c
+}
+
+// TrelloSubscriber receives every event published by a TrelloHandler
+type TrelloSubscriber interface {
+	HandleTrelloEvent(event TrelloEvent) error
+}
+
+type TrelloConfig struct {
+	// Secret is the trello application secret the payloads are signed with
+	Secret string
+	// CallbackURL is the URL the webhook was registered with, it is part of the signature
+	CallbackURL string
+	// Handled, if set, is called after each queued event reached every subscriber
+	Handled func()
+}
+
+type TrelloHandler struct {
+	secret      []byte
+	callbackURL string
+
+	subscribers []TrelloSubscriber
+	handled     func()
+
+	// mu guards queue against sends once Close has closed it
+	mu     sync.RWMutex
+	closed bool
+	queue  chan TrelloEvent
+	done   chan struct{}
+}
+
+// trelloPayload holds the parts of a board action needed to build an event
+type trelloPayload struct {
+	Action struct {
+		ID            string `json:"id"`
+		Type          string `json:"type"`
+		MemberCreator *struct {
+			FullName string `json:"fullName"`
+		} `json:"memberCreator"`
+		Data struct {
+			Text       string      `json:"text"`
+			Card       *TrelloCard `json:"card"`
+			ListBefore *struct {
+				ID string `json:"id"`
+			} `json:"listBefore"`
+			ListAfter *struct {
+				ID string `json:"id"`
+			} `json:"listAfter"`
+			Old *struct {
+				Closed *bool `json:"closed"`
+			} `json:"old"`
+			Label *struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"label"`
+		} `json:"data"`
+	} `json:"action"`
+}
+
+// NewTrelloHandler returns a handler for trello webhooks. Subscribers are
+// notified one event at a time in the background, call Close to stop.
+func NewTrelloHandler(config TrelloConfig) *TrelloHandler {
+	h := &TrelloHandler{
+		secret:      []byte(config.Secret),
+		callbackURL: config.CallbackURL,
+		handled:     config.Handled,
+		queue:       make(chan TrelloEvent, queueSize),
+		done:        make(chan struct{}),
+	}
+	go h.work()
+	return h
+}
+
+// Subscribe adds a subscriber, it must be called before serving
+func (h *TrelloHandler) Subscribe(subscriber TrelloSubscriber) {
+	h.subscribers = append(h.subscribers, subscriber)
+}
+
+// Close stops accepting work and waits for queued events to be handled
+func (h *TrelloHandler) Close() {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+	<-h.done
+}
+
+func (h *TrelloHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodHead:
+		// trello checks the callback URL when the webhook is registered
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "unable to read payload", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validSignature(r.Header.Get("X-Trello-Webhook"), body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, actionType, err := h.parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// trello disables webhooks that don't answer 2xx, so ignored actions succeed too
+	if event == nil {
+		fmt.Printf("Ignoring trello %s action\n", actionType)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case h.queue <- event:
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "event queue full", http.StatusServiceUnavailable)
+	}
+}
+
+// validSignature checks the base64 HMAC-SHA1 of the body followed by the callback URL
+func (h *TrelloHandler) validSignature(signature string, body []byte) bool {
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, h.secret)
+	mac.Write(body)
+	mac.Write([]byte(h.callbackURL))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parse maps a board action to a card event, nil if unsupported
+func (h *TrelloHandler) parse(body []byte) (TrelloEvent, string, error) {
+	p := &trelloPayload{}
+	if err := json.Unmarshal(body, p); err != nil {
+		return nil, "", errors.Wrap(err, "Unable to decode trello payload")
+	}
+
+	action := p.Action
+	data := action.Data
+	if data.Card == nil {
+		return nil, action.Type, nil
+	}
+	card := *data.Card
+
+	switch action.Type {
+	case "updateCard":
+		if data.ListBefore != nil && data.ListAfter != nil {
+			return CardMovedEvent{card, data.ListBefore.ID, data.ListAfter.ID}, action.Type, nil
+		}
+		if data.Old != nil && data.Old.Closed != nil {
+			return CardArchivedEvent{card, !*data.Old.Closed}, action.Type, nil
+		}
+	case "commentCard":
+		author := ""
+		if action.MemberCreator != nil {
+			author = action.MemberCreator.FullName
+		}
+		return CardCommentedEvent{card, action.ID, author, data.Text}, action.Type, nil
+	case "addLabelToCard", "removeLabelFromCard":
+		if data.Label == nil {
+			return nil, "", errors.Errorf("%s payload has no label", action.Type)
+		}
+		return CardLabeledEvent{
+			card,
+			data.Label.ID,
+			data.Label.Name,
+			action.Type == "addLabelToCard",
+		}, action.Type, nil
+	}
+	return nil, action.Type, nil
+}
+
+func (h *TrelloHandler) work() {
+	defer close(h.done)
+	for event := range h.queue {
+		card := event.Card()
+		fmt.Printf("Handling trello %T for card \"%s\"\n", event, card.Name)
+		for _, subscriber := range h.subscribers {
+			if err := subscriber.HandleTrelloEvent(event); err != nil {
+				log.Printf("[ERROR] Handling trello event for card %s failed: %s", card.ID, err)
+			}
+		}
+		if h.handled != nil {
+			h.handled()
+		}
+	}
+}